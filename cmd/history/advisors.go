@@ -0,0 +1,23 @@
+package main
+
+import (
+	advisors "advisordev/internal/advisors_sample"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+func advisorsHandler(args []string) error {
+	var flagset = flag.NewFlagSet("", flag.ExitOnError)
+	flagset.Parse(args)
+
+	var w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, info := range advisors.List() {
+		fmt.Fprintf(w, "%v - %v\n", info.Name, info.Description)
+		for _, param := range info.Params {
+			fmt.Fprintf(w, "  %v\t%v\t%v\t%v\n", param.Name, param.Kind, param.Default, param.Description)
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"advisordev/internal/cli"
+	"advisordev/internal/moex"
+	"flag"
+	"fmt"
+	"time"
+)
+
+// Если calendarPath пуст, то календарь, встроенный в программу
+func fortsCalendar(calendarPath string) (*moex.Calendar, error) {
+	if calendarPath == "" {
+		return moex.FortsCalendar, nil
+	}
+	return moex.LoadCalendar(cli.MapPath(calendarPath))
+}
+
+func calendarHandler(args []string) error {
+	var (
+		calendarPath string
+		timeValue    string
+	)
+	var flagset = flag.NewFlagSet("", flag.ExitOnError)
+	flagset.StringVar(&calendarPath, "calendar", calendarPath, "")
+	flagset.StringVar(&timeValue, "time", timeValue, "")
+	flagset.Parse(args)
+
+	calendar, err := fortsCalendar(calendarPath)
+	if err != nil {
+		return err
+	}
+	var t = time.Now().In(moex.TimeZone)
+	if timeValue != "" {
+		t, err = time.ParseInLocation("2006-01-02 15:04", timeValue, moex.TimeZone)
+		if err != nil {
+			t, err = time.ParseInLocation(time.DateOnly, timeValue, moex.TimeZone)
+		}
+		if err != nil {
+			return fmt.Errorf("bad time %v", timeValue)
+		}
+	}
+	var session = calendar.Session(t)
+	if session == moex.SessionClosed {
+		session = "нет"
+	}
+	fmt.Printf("%-20v %v\n", "Время", t.Format("02.01.2006 15:04 Mon"))
+	fmt.Printf("%-20v %v\n", "Торговый день", calendar.IsTradingDay(t))
+	fmt.Printf("%-20v %v\n", "Сессия", session)
+	fmt.Printf("%-20v %v\n", "Клиринг", calendar.IsClearing(t))
+	fmt.Printf("%-20v %v\n", "Торговая дата", calendar.TradingDate(t).Format("02.01.2006"))
+	fmt.Printf("%-20v %v\n", "След. торговый день", calendar.NextTradingDay(t).Format("02.01.2006"))
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"advisordev/internal/candles"
+	"advisordev/internal/cli"
+	"advisordev/internal/domain"
+	"advisordev/internal/moex"
+	"advisordev/internal/trader"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+func driftHandler(args []string) error {
+	var (
+		configPath   string = "trader.xml"
+		logPattern   string = "~/TradingData/Logs/luatrader/*.txt"
+		securityName string
+	)
+
+	var flagset = flag.NewFlagSet("", flag.ExitOnError)
+	flagset.StringVar(&configPath, "config", configPath, "")
+	flagset.StringVar(&logPattern, "log", logPattern, "")
+	flagset.StringVar(&securityName, "security", securityName, "")
+	flagset.Parse(args)
+
+	config, err := trader.LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	if securityName != "" {
+		replaceSecurity(&config, securityName)
+	}
+
+	logPaths, err := filepath.Glob(cli.MapPath(logPattern))
+	if err != nil {
+		return err
+	}
+	if len(logPaths) == 0 {
+		return fmt.Errorf("no trader logs %v", logPattern)
+	}
+	traderLog, err := trader.LoadTraderLog(logPaths)
+	if err != nil {
+		return err
+	}
+
+	var logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	var candleStorage = candles.NewCandleStorage(cli.MapPath("~/TradingData"), domain.CandleIntervalMinutes5, moex.TimeZone)
+	securities, err := securityInformator()
+	if err != nil {
+		return err
+	}
+	report, err := trader.DetectDrift(logger, candleStorage, config, securities, traderLog)
+	if err != nil {
+		return err
+	}
+	printDriftReport(os.Stdout, len(logPaths), traderLog, report)
+	return nil
+}
+
+func printDriftReport(w io.Writer, logs int, traderLog trader.TraderLog, report trader.DriftReport) {
+	fmt.Fprintf(w, "Логов: %v, советов: %v, баров: %v\n", logs, len(traderLog.Advices), len(traderLog.Candles))
+	fmt.Fprintf(w, "Сравнено советов и изменений позиции на истории: %v, расхождений позиций: %v, расхождений баров: %v\n",
+		report.Advices, len(report.Drifts), len(report.CandleDiffs))
+	for _, drift := range report.Drifts {
+		var historyPosition = fmt.Sprintf("%.4f", drift.HistoryPosition)
+		if drift.HistoryMissing {
+			historyPosition = "нет совета"
+		}
+		var livePosition = fmt.Sprintf("%.4f", drift.LivePosition)
+		if drift.LiveMissing {
+			livePosition += " (нет совета)"
+		}
+		fmt.Fprintf(w, "\n%v %v %v позиция %v, на истории %v\n",
+			drift.DateTime.Format(time.DateTime), drift.Advisor, drift.SecurityCode,
+			livePosition, historyPosition)
+		for _, diff := range drift.Causes {
+			fmt.Fprintf(w, "  бар %v лог: %v, история: %v\n",
+				diff.DateTime.Format(time.DateTime), formatDriftCandle(diff.Live), formatDriftCandle(diff.History))
+		}
+	}
+}
+
+func formatDriftCandle(candle domain.Candle) string {
+	if candle.DateTime.IsZero() {
+		return "нет"
+	}
+	var f = func(x float64) string {
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	}
+	return fmt.Sprintf("O=%v H=%v L=%v C=%v V=%v",
+		f(candle.OpenPrice), f(candle.HighPrice), f(candle.LowPrice), f(candle.ClosePrice), f(candle.Volume))
+}
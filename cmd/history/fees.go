@@ -0,0 +1,41 @@
+package main
+
+import (
+	"advisordev/internal/history"
+	"advisordev/internal/moex"
+)
+
+// Комиссия за заключение сделок + Клиринговая комиссия + Комисия брокера + Проскальзывание
+// https://www.moex.com/s93
+const defaultSlippage = ((0.00462+0.00154)*2 + 0.01) * 0.01
+
+// Если feesPath пуст, то постоянные издержки slippage (доля цены, 0 - без издержек).
+// Иначе по тарифам из feesPath, а до первого тарифа - slippage.
+func costModel(slippage float64, feesPath string) (history.CostModel, error) {
+	if feesPath == "" {
+		return history.ConstantCost(slippage), nil
+	}
+	config, err := moex.LoadFeeConfig(feesPath)
+	if err != nil {
+		return nil, err
+	}
+	securities, err := securityInformator()
+	if err != nil {
+		return nil, err
+	}
+	fees, err := moex.NewFeeModel(config, securities)
+	if err != nil {
+		return nil, err
+	}
+	return history.FeeCost(fees, slippage), nil
+}
+
+// Правило перехода на следующий контракт
+func rollSettings(rule string, days int) (history.RollSettings, error) {
+	var result = history.RollSettings{Rule: rule, Days: days}
+	var err = result.Validate()
+	if err != nil {
+		return history.RollSettings{}, err
+	}
+	return result, nil
+}
@@ -11,6 +11,16 @@ func main() {
 	app.AddCommand("report", reportHandler)
 	app.AddCommand("testdownload", testDownloadHandler)
 	app.AddCommand("update", updateHandler)
+	app.AddCommand("advisors", advisorsHandler)
+	app.AddCommand("optimize", optimizeHandler)
+	app.AddCommand("walkforward", walkForwardHandler)
+	app.AddCommand("portfolio", portfolioHandler)
+	app.AddCommand("replay", replayHandler)
+	app.AddCommand("drift", driftHandler)
+	app.AddCommand("runs", runsHandler)
+	app.AddCommand("rundiff", runDiffHandler)
+	app.AddCommand("calendar", calendarHandler)
+	app.AddCommand("securities", securitiesHandler)
 	var err = app.Run()
 	if err != nil {
 		slog.Error("run failed",
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"advisordev/internal/candles"
+	"advisordev/internal/cli"
+	"advisordev/internal/domain"
+	"advisordev/internal/history"
+	"advisordev/internal/moex"
+	"flag"
+	"fmt"
+	"runtime"
+	"time"
+)
+
+type optimizeOptions struct {
+	advisorName   string
+	paramRanges   string
+	timeframeName string
+	securityName  string
+	lever         float64
+	slippage      float64
+	feesPath      string
+	startYear     int
+	startQuarter  int
+	finishYear    int
+	finishQuarter int
+	multiContract bool
+	roll          string
+	rollDays      int
+	strict        bool
+	objective     string
+	maxDrawdown   float64
+	samples       int
+	seed          int64
+}
+
+// Общие флаги optimize и walkforward
+func newOptimizeOptions(flagset *flag.FlagSet) *optimizeOptions {
+	var today = time.Now()
+	var o = &optimizeOptions{
+		timeframeName: domain.CandleIntervalMinutes5,
+		slippage:      defaultSlippage,
+		startYear:     today.Year(),
+		startQuarter:  0,
+		finishYear:    today.Year(),
+		finishQuarter: 3,
+		multiContract: true,
+		roll:          history.RollData,
+		rollDays:      5,
+		objective:     history.ObjectiveHpr,
+		seed:          today.UnixNano(),
+	}
+	flagset.StringVar(&o.advisorName, "advisor", o.advisorName, "")
+	flagset.StringVar(&o.paramRanges, "grid", o.paramRanges, "")
+	flagset.StringVar(&o.timeframeName, "timeframe", o.timeframeName, "")
+	flagset.StringVar(&o.securityName, "security", o.securityName, "")
+	flagset.Float64Var(&o.lever, "lever", o.lever, "")
+	flagset.Float64Var(&o.slippage, "slippage", o.slippage, "")
+	flagset.StringVar(&o.feesPath, "fees", o.feesPath, "")
+	flagset.IntVar(&o.startYear, "startyear", o.startYear, "")
+	flagset.IntVar(&o.startQuarter, "startquarter", o.startQuarter, "")
+	flagset.IntVar(&o.finishYear, "finishyear", o.finishYear, "")
+	flagset.IntVar(&o.finishQuarter, "finishquarter", o.finishQuarter, "")
+	flagset.BoolVar(&o.multiContract, "multy", o.multiContract, "")
+	flagset.StringVar(&o.roll, "roll", o.roll, "")
+	flagset.IntVar(&o.rollDays, "rolldays", o.rollDays, "")
+	flagset.BoolVar(&o.strict, "strict", o.strict, "")
+	flagset.StringVar(&o.objective, "objective", o.objective, "")
+	flagset.Float64Var(&o.maxDrawdown, "maxdrawdown", o.maxDrawdown, "")
+	flagset.IntVar(&o.samples, "samples", o.samples, "")
+	flagset.Int64Var(&o.seed, "seed", o.seed, "")
+	return o
+}
+
+func (o *optimizeOptions) settings() (history.OptimizeSettings, error) {
+	ranges, err := history.ParseParamRanges(o.paramRanges)
+	if err != nil {
+		return history.OptimizeSettings{}, err
+	}
+	cost, err := costModel(o.slippage, o.feesPath)
+	if err != nil {
+		return history.OptimizeSettings{}, err
+	}
+	roll, err := rollSettings(o.roll, o.rollDays)
+	if err != nil {
+		return history.OptimizeSettings{}, err
+	}
+	return history.OptimizeSettings{
+		AdvisorName:  o.advisorName,
+		ParamRanges:  ranges,
+		SecurityName: o.securityName,
+		TimeRange: moex.TimeRange{
+			StartYear:     o.startYear,
+			StartQuarter:  o.startQuarter,
+			FinishYear:    o.finishYear,
+			FinishQuarter: o.finishQuarter,
+		},
+		MultiContract: o.multiContract,
+		Cost:          cost,
+		Roll:          roll,
+		Strict:        o.strict,
+		Lever:         o.lever,
+		Objective:     o.objective,
+		MaxDrawdown:   o.maxDrawdown,
+		Samples:       o.samples,
+		Seed:          o.seed,
+		Concurrency:   runtime.NumCPU(),
+	}, nil
+}
+
+func (o *optimizeOptions) candleStorage() domain.ICandleStorage {
+	return candles.NewCachedCandleStorage(
+		candles.NewCandleStorage(cli.MapPath("~/TradingData"), o.timeframeName, moex.TimeZone))
+}
+
+func optimizeHandler(args []string) error {
+	var (
+		top     int = 20
+		outPath string
+	)
+
+	var flagset = flag.NewFlagSet("", flag.ExitOnError)
+	var options = newOptimizeOptions(flagset)
+	flagset.IntVar(&top, "top", top, "")
+	flagset.StringVar(&outPath, "out", outPath, "")
+	flagset.Parse(args)
+
+	settings, err := options.settings()
+	if err != nil {
+		return err
+	}
+
+	var start = time.Now()
+	runs, err := history.Optimize(options.candleStorage(), settings)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Оптимизация", settings.AdvisorName, settings.SecurityName, "цель", settings.Objective, "прогонов", len(runs))
+	history.PrintOptimizationRuns(runs, top)
+	fmt.Println("Elapsed:", time.Since(start))
+
+	if outPath != "" {
+		return history.WriteOptimizationRuns(outPath, runs)
+	}
+	return nil
+}
+
+func walkForwardHandler(args []string) error {
+	var (
+		inSample  int = 8
+		outSample int = 1
+	)
+
+	var flagset = flag.NewFlagSet("", flag.ExitOnError)
+	var options = newOptimizeOptions(flagset)
+	flagset.IntVar(&inSample, "insample", inSample, "")
+	flagset.IntVar(&outSample, "outsample", outSample, "")
+	flagset.Parse(args)
+
+	settings, err := options.settings()
+	if err != nil {
+		return err
+	}
+
+	var start = time.Now()
+	result, err := history.WalkForward(options.candleStorage(), history.WalkForwardSettings{
+		Optimize:  settings,
+		InSample:  inSample,
+		OutSample: outSample,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Walk-forward", settings.AdvisorName, settings.SecurityName, "цель", settings.Objective)
+	history.PrintWalkForward(result)
+	fmt.Println("Elapsed:", time.Since(start))
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	advisors "advisordev/internal/advisors_sample"
+	"advisordev/internal/candles"
+	"advisordev/internal/cli"
+	"advisordev/internal/domain"
+	"advisordev/internal/history"
+	"advisordev/internal/moex"
+	"advisordev/internal/trader"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+func portfolioHandler(args []string) error {
+	var today = time.Now()
+	var (
+		components    string
+		configPath    string
+		timeframeName string = domain.CandleIntervalMinutes5
+		lever         float64
+		slippage      float64 = defaultSlippage
+		feesPath      string
+		startYear     int    = today.Year()
+		startQuarter  int    = 0
+		finishYear    int    = today.Year()
+		finishQuarter int    = 3
+		multiContract bool   = true
+		roll          string = history.RollData
+		rollDays      int    = 5
+		strict        bool
+	)
+
+	var flagset = flag.NewFlagSet("", flag.ExitOnError)
+	flagset.StringVar(&components, "components", components, "")
+	flagset.StringVar(&configPath, "config", configPath, "")
+	flagset.StringVar(&timeframeName, "timeframe", timeframeName, "")
+	flagset.Float64Var(&lever, "lever", lever, "")
+	flagset.Float64Var(&slippage, "slippage", slippage, "")
+	flagset.StringVar(&feesPath, "fees", feesPath, "")
+	flagset.IntVar(&startYear, "startyear", startYear, "")
+	flagset.IntVar(&startQuarter, "startquarter", startQuarter, "")
+	flagset.IntVar(&finishYear, "finishyear", finishYear, "")
+	flagset.IntVar(&finishQuarter, "finishquarter", finishQuarter, "")
+	flagset.BoolVar(&multiContract, "multy", multiContract, "")
+	flagset.StringVar(&roll, "roll", roll, "")
+	flagset.IntVar(&rollDays, "rolldays", rollDays, "")
+	flagset.BoolVar(&strict, "strict", strict, "")
+	flagset.Parse(args)
+
+	var portfolio []history.PortfolioComponent
+	if configPath != "" {
+		config, err := trader.LoadConfig(configPath)
+		if err != nil {
+			return err
+		}
+		portfolio, err = signalComponents(config.Signals, multiContract)
+		if err != nil {
+			return err
+		}
+	}
+	parsedComponents, err := history.ParsePortfolioComponents(components)
+	if err != nil {
+		return err
+	}
+	portfolio = append(portfolio, parsedComponents...)
+	cost, err := costModel(slippage, feesPath)
+	if err != nil {
+		return err
+	}
+	rollSettings, err := rollSettings(roll, rollDays)
+	if err != nil {
+		return err
+	}
+
+	var start = time.Now()
+	var candleStorage = candles.NewCandleStorage(cli.MapPath("~/TradingData"), timeframeName, moex.TimeZone)
+	result, err := history.PortfolioBacktest(candleStorage, history.PortfolioSettings{
+		Components: portfolio,
+		Lever:      lever,
+		Cost:       cost,
+		Roll:       rollSettings,
+		Strict:     strict,
+		TimeRange: moex.TimeRange{
+			StartYear:     startYear,
+			StartQuarter:  startQuarter,
+			FinishYear:    finishYear,
+			FinishQuarter: finishQuarter,
+		},
+		MultiContract: multiContract,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Портфель")
+	history.PrintPortfolio(result)
+	fmt.Println("Elapsed:", time.Since(start))
+	return nil
+}
+
+// Сигналы из trader.xml. Для тестирования нескольких контрактов берем базовый актив: Si-3.25 -> Si.
+func signalComponents(signals []trader.SignalConfig, multiContract bool) ([]history.PortfolioComponent, error) {
+	var result []history.PortfolioComponent
+	for _, signal := range signals {
+		params, err := signal.AdvisorParams()
+		if err != nil {
+			return nil, err
+		}
+		var securityName = signal.Security
+		if multiContract {
+			securityName, _, _ = strings.Cut(securityName, "-")
+		}
+		var weight = signal.Weight
+		if weight == 0 {
+			weight = 1
+		}
+		result = append(result, history.PortfolioComponent{
+			AdvisorName:   signal.Advisor,
+			AdvisorParams: advisors.WithStdVolatility(signal.Advisor, params, signal.StdVolatility),
+			SecurityName:  securityName,
+			Weight:        weight,
+		})
+	}
+	return result, nil
+}
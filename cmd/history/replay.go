@@ -0,0 +1,110 @@
+package main
+
+import (
+	"advisordev/internal/candles"
+	"advisordev/internal/cli"
+	"advisordev/internal/domain"
+	"advisordev/internal/history"
+	"advisordev/internal/moex"
+	"advisordev/internal/trader"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+func replayHandler(args []string) error {
+	var (
+		configPath   string = "trader.xml"
+		securityName string
+		capital      float64 = 1_000_000
+		verbose      bool
+	)
+
+	var flagset = flag.NewFlagSet("", flag.ExitOnError)
+	flagset.StringVar(&configPath, "config", configPath, "")
+	flagset.StringVar(&securityName, "security", securityName, "")
+	flagset.Float64Var(&capital, "capital", capital, "")
+	flagset.BoolVar(&verbose, "v", verbose, "")
+	flagset.Parse(args)
+
+	config, err := trader.LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	if securityName != "" {
+		replaceSecurity(&config, securityName)
+	}
+
+	var level = slog.LevelError
+	if verbose {
+		level = slog.LevelDebug
+	}
+	var logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+
+	var start = time.Now()
+	var candleStorage = candles.NewCandleStorage(cli.MapPath("~/TradingData"), domain.CandleIntervalMinutes5, moex.TimeZone)
+	securities, err := securityInformator()
+	if err != nil {
+		return err
+	}
+	result, err := trader.Replay(logger, candleStorage, config, securities, capital)
+	if err != nil {
+		return err
+	}
+	if len(result.Days) == 0 {
+		return fmt.Errorf("no data")
+	}
+
+	fmt.Println("Заявки")
+	printReplayOrders(result.Orders)
+	fmt.Println("Эквити")
+	history.ReportDailyResults(replayHprs(result))
+	fmt.Println("Elapsed:", time.Since(start))
+	return nil
+}
+
+// Все сигналы и стратегии на другой контракт, например Si-6.25 вместо Si-3.25
+func replaceSecurity(config *trader.TraderConfig, securityName string) {
+	for i := range config.Signals {
+		config.Signals[i].Security = securityName
+	}
+	for i := range config.Clients {
+		for j := range config.Clients[i].Portfolios {
+			var strategies = config.Clients[i].Portfolios[j].Strategies
+			for k := range strategies {
+				strategies[k].Security = securityName
+			}
+		}
+	}
+}
+
+func replayHprs(result trader.ReplayResult) []history.DateSum {
+	var hprs = make([]history.DateSum, len(result.Days))
+	var equity = result.StartEquity
+	for i, day := range result.Days {
+		hprs[i] = history.DateSum{Date: day.Date, Sum: day.Equity / equity}
+		equity = day.Equity
+	}
+	return hprs
+}
+
+func printReplayOrders(orders []trader.ReplayOrder) {
+	var w = tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "Время\tПортфель\tИнструмент\tОбъем\tЦена\tИсполнение\tЦена исп.\t\n")
+	for _, order := range orders {
+		var status, fillPrice = "", ""
+		if order.Filled {
+			status = order.FillTime.Format(time.DateTime)
+			fillPrice = fmt.Sprintf("%.2f", order.FillPrice)
+		} else if order.Cancelled {
+			status = "снята"
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%.2f\t%v\t%v\t\n",
+			order.Time.Format(time.DateTime), order.Portfolio, order.Security,
+			order.Volume, order.Price, status, fillPrice)
+	}
+	w.Flush()
+}
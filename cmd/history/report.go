@@ -1,47 +1,227 @@
 package main
 
 import (
+	advisors "advisordev/internal/advisors_sample"
 	"advisordev/internal/candles"
 	"advisordev/internal/cli"
 	"advisordev/internal/domain"
 	"advisordev/internal/history"
 	"advisordev/internal/moex"
 	"flag"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
-// Комиссия за заключение сделок + Клиринговая комиссия + Комисия брокера + Проскальзывание
-// https://www.moex.com/s93
-const defaultSlippage = ((0.00462+0.00154)*2 + 0.01) * 0.01
-
 func reportHandler(args []string) error {
 	var today = time.Now()
 	var (
 		advisorName   string
+		advisorParams string
 		timeframeName string = domain.CandleIntervalMinutes5
 		securityName  string
 		lever         float64
+		sizing        string = history.SizingStDev
+		sizingTarget  float64
+		sizingLevel   float64 = 0.05
 		slippage      float64 = defaultSlippage
-		startYear     int     = today.Year()
-		startQuarter  int     = 0
-		finishYear    int     = today.Year()
-		finishQuarter int     = 3
-		multiContract bool    = true
+		feesPath      string
+		startYear     int    = today.Year()
+		startQuarter  int    = 0
+		finishYear    int    = today.Year()
+		finishQuarter int    = 3
+		multiContract bool   = true
+		roll          string = history.RollData
+		rollDays      int    = 5
+		strict        bool
+
+		monteCarloMethod string  = history.ResampleBlock
+		simulations      int     = 0
+		blockLength      float64 = 10
+		ruinEquity       float64 = 0.5
+
+		trades     bool
+		tradesPath string
+
+		format     string
+		outputPath string
+		runStore   string
+
+		cvarLevels string = "0.01,0.05"
+
+		sensSlippages   string
+		sensMultipliers string
+
+		benchmark              string
+		benchmarkMultiContract bool = true
+
+		capital       float64
+		fill          string  = history.FillNextOpen
+		limitSlippage float64 = 0.001
 	)
 
 	var flagset = flag.NewFlagSet("", flag.ExitOnError)
 	flagset.StringVar(&advisorName, "advisor", advisorName, "")
+	flagset.StringVar(&advisorParams, "params", advisorParams, "")
 	flagset.StringVar(&timeframeName, "timeframe", timeframeName, "")
 	flagset.StringVar(&securityName, "security", securityName, "")
 	flagset.Float64Var(&lever, "lever", lever, "")
+	flagset.StringVar(&sizing, "sizing", sizing, "")
+	flagset.Float64Var(&sizingTarget, "sizingtarget", sizingTarget, "")
+	flagset.Float64Var(&sizingLevel, "sizinglevel", sizingLevel, "")
 	flagset.Float64Var(&slippage, "slippage", slippage, "")
+	flagset.StringVar(&feesPath, "fees", feesPath, "")
 	flagset.IntVar(&startYear, "startyear", startYear, "")
 	flagset.IntVar(&startQuarter, "startquarter", startQuarter, "")
 	flagset.IntVar(&finishYear, "finishyear", finishYear, "")
 	flagset.IntVar(&finishQuarter, "finishquarter", finishQuarter, "")
 	flagset.BoolVar(&multiContract, "multy", multiContract, "")
+	flagset.StringVar(&roll, "roll", roll, "")
+	flagset.IntVar(&rollDays, "rolldays", rollDays, "")
+	flagset.BoolVar(&strict, "strict", strict, "")
+	flagset.StringVar(&monteCarloMethod, "resample", monteCarloMethod, "")
+	flagset.IntVar(&simulations, "simulations", simulations, "")
+	flagset.Float64Var(&blockLength, "block", blockLength, "")
+	flagset.Float64Var(&ruinEquity, "ruin", ruinEquity, "")
+	flagset.BoolVar(&trades, "trades", trades, "")
+	flagset.StringVar(&tradesPath, "tradesout", tradesPath, "")
+	flagset.StringVar(&format, "format", format, "")
+	flagset.StringVar(&outputPath, "output", outputPath, "")
+	flagset.StringVar(&runStore, "runs", runStore, "")
+	flagset.StringVar(&cvarLevels, "cvar", cvarLevels, "")
+	flagset.StringVar(&sensSlippages, "sensslippage", sensSlippages, "")
+	flagset.StringVar(&sensMultipliers, "sensfees", sensMultipliers, "")
+	flagset.Float64Var(&capital, "capital", capital, "")
+	flagset.StringVar(&fill, "fill", fill, "")
+	flagset.Float64Var(&limitSlippage, "limitslippage", limitSlippage, "")
+	flagset.StringVar(&benchmark, "benchmark", benchmark, "")
+	flagset.BoolVar(&benchmarkMultiContract, "benchmarkmulty", benchmarkMultiContract, "")
 	flagset.Parse(args)
 
-	var candleStorage = candles.NewCandleStorage(cli.MapPath("~/TradingData"), timeframeName, moex.TimeZone)
-	return history.AdvisorReport(candleStorage, advisorName, securityName, lever, slippage, startYear, startQuarter, finishYear, finishQuarter, multiContract)
+	if format == "" {
+		format = history.ReportFormatByPath(outputPath)
+	}
+
+	params, err := advisors.ParseParams(advisorParams)
+	if err != nil {
+		return err
+	}
+	levels, err := parseLevels(cvarLevels)
+	if err != nil {
+		return err
+	}
+	cost, err := costModel(slippage, feesPath)
+	if err != nil {
+		return err
+	}
+	securities, err := securityInformator()
+	if err != nil {
+		return err
+	}
+	slippages, err := parseCosts(sensSlippages)
+	if err != nil {
+		return err
+	}
+	multipliers, err := parseCosts(sensMultipliers)
+	if err != nil {
+		return err
+	}
+	rollSettings, err := rollSettings(roll, rollDays)
+	if err != nil {
+		return err
+	}
+
+	var candleStorage domain.ICandleStorage = candles.NewCandleStorage(cli.MapPath("~/TradingData"), timeframeName, moex.TimeZone)
+	if len(slippages) != 0 || len(multipliers) != 0 {
+		// перебор издержек прогоняет советник многократно
+		candleStorage = candles.NewCachedCandleStorage(candleStorage)
+	}
+	return history.AdvisorReport(candleStorage, history.ReportSettings{
+		AdvisorName:   advisorName,
+		AdvisorParams: params,
+		SecurityName:  securityName,
+		Lever:         lever,
+		Sizing: history.SizingSettings{
+			Method: sizing,
+			Target: sizingTarget,
+			Level:  sizingLevel,
+		},
+		Cost: cost,
+		TimeRange: moex.TimeRange{
+			StartYear:     startYear,
+			StartQuarter:  startQuarter,
+			FinishYear:    finishYear,
+			FinishQuarter: finishQuarter,
+		},
+		MultiContract: multiContract,
+		Roll:          rollSettings,
+		Strict:        strict,
+		MonteCarlo: history.MonteCarloSettings{
+			Method:      monteCarloMethod,
+			Simulations: simulations,
+			BlockLength: blockLength,
+			RuinEquity:  ruinEquity,
+			Seed:        today.UnixNano(),
+		},
+		Trades:     trades,
+		TradesPath: tradesPath,
+		Execution: history.ExecutionSettings{
+			Capital:       capital,
+			Fill:          fill,
+			LimitSlippage: limitSlippage,
+		},
+		Securities:             securities,
+		Benchmark:              benchmark,
+		BenchmarkMultiContract: benchmarkMultiContract,
+		Sensitivity: history.SensitivitySettings{
+			Slippages:       slippages,
+			CostMultipliers: multipliers,
+		},
+		CVaRLevels: levels,
+		Format:     format,
+		OutputPath: outputPath,
+		RunStore:   cli.MapPath(runStore),
+		Args:       args,
+	})
+}
+
+// Разбирает список долей через запятую, например "0.01,0.05"
+func parseLevels(s string) ([]float64, error) {
+	var result []float64
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		level, err := strconv.ParseFloat(item, 64)
+		if err != nil {
+			return nil, err
+		}
+		if !(level > 0 && level < 1) {
+			return nil, fmt.Errorf("bad level %v", item)
+		}
+		result = append(result, level)
+	}
+	return result, nil
+}
+
+// Разбирает список неотрицательных издержек через запятую, например "0,0.0002,0.0005"
+func parseCosts(s string) ([]float64, error) {
+	var result []float64
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		cost, err := strconv.ParseFloat(item, 64)
+		if err != nil {
+			return nil, err
+		}
+		if !(cost >= 0) {
+			return nil, fmt.Errorf("bad cost %v", item)
+		}
+		result = append(result, cost)
+	}
+	return result, nil
 }
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"advisordev/internal/cli"
+	"advisordev/internal/history"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// Каталог хранилища прогонов report по умолчанию
+const defaultRunStore = "~/TradingData/Runs"
+
+func runsHandler(args []string) error {
+	var (
+		runStore     string = defaultRunStore
+		advisorName  string
+		securityName string
+		last         int = 20
+	)
+	var flagset = flag.NewFlagSet("", flag.ExitOnError)
+	flagset.StringVar(&runStore, "runs", runStore, "")
+	flagset.StringVar(&advisorName, "advisor", advisorName, "")
+	flagset.StringVar(&securityName, "security", securityName, "")
+	flagset.IntVar(&last, "last", last, "")
+	flagset.Parse(args)
+
+	runs, err := history.ListRuns(cli.MapPath(runStore))
+	if err != nil {
+		return err
+	}
+	var filtered []history.RunInfo
+	for _, run := range runs {
+		if advisorName != "" && run.AdvisorName != advisorName ||
+			securityName != "" && run.SecurityName != securityName {
+			continue
+		}
+		filtered = append(filtered, run)
+	}
+	if last != 0 && len(filtered) > last {
+		filtered = filtered[len(filtered)-last:]
+	}
+	history.PrintRuns(os.Stdout, filtered)
+	return nil
+}
+
+func runDiffHandler(args []string) error {
+	var runStore string = defaultRunStore
+	var flagset = flag.NewFlagSet("", flag.ExitOnError)
+	flagset.StringVar(&runStore, "runs", runStore, "")
+	flagset.Parse(args)
+
+	if flagset.NArg() != 2 {
+		return fmt.Errorf("two run ids expected")
+	}
+	var dir = cli.MapPath(runStore)
+	a, err := history.LoadRun(dir, flagset.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := history.LoadRun(dir, flagset.Arg(1))
+	if err != nil {
+		return err
+	}
+	history.PrintRunDiff(os.Stdout, a, b)
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"advisordev/internal/moex"
+	"advisordev/internal/quik"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// Справочник инструментов по умолчанию, как trader.xml
+const defaultSecuritiesPath = "securities.xml"
+
+// Справочник из defaultSecuritiesPath. Если файла нет, то встроенный в программу.
+func securityInformator() (*moex.SecurityInformator, error) {
+	return moex.LoadSecurityInformator(defaultSecuritiesPath)
+}
+
+func securitiesHandler(args []string) error {
+	var (
+		securitiesPath string = defaultSecuritiesPath
+		refresh        bool
+		port           int = 34128
+	)
+	var flagset = flag.NewFlagSet("", flag.ExitOnError)
+	flagset.StringVar(&securitiesPath, "securities", securitiesPath, "")
+	flagset.BoolVar(&refresh, "refresh", refresh, "")
+	flagset.IntVar(&port, "port", port, "")
+	flagset.Parse(args)
+
+	config, err := moex.LoadSecurityConfigOrDefault(securitiesPath)
+	if err != nil {
+		return err
+	}
+	if refresh {
+		var logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+		var connector = quik.NewQuikConnector(logger, port)
+		defer connector.Close()
+		err = connector.Init()
+		if err != nil {
+			return err
+		}
+		config, err = moex.RefreshSecurityConfig(config, connector, time.Now().In(moex.TimeZone))
+		if err != nil {
+			return err
+		}
+	}
+	// проверка справочника, в том числе перед сохранением обновленного
+	_, err = moex.NewSecurityInformator(config)
+	if err != nil {
+		return err
+	}
+	if refresh {
+		err = moex.SaveSecurityConfig(securitiesPath, config)
+		if err != nil {
+			return err
+		}
+	}
+
+	var w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Base\tClass\tPrecision\tPriceStep\tStepCost\tLot\tLever\t")
+	for _, item := range config.Securities {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t\n",
+			item.Base, item.ClassCode, item.PricePrecision, item.PriceStep, item.PriceStepCost, item.Lot, item.Lever)
+	}
+	return w.Flush()
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	advisors "advisordev/internal/advisors_sample"
 	"advisordev/internal/candles"
 	"advisordev/internal/cli"
 	"advisordev/internal/domain"
@@ -12,16 +13,49 @@ import (
 func statusHandler(args []string) error {
 	var (
 		advisorName   string
+		advisorParams string
 		timeframeName string = domain.CandleIntervalMinutes5
 		securityName  string
+		startDate     cli.DateValue
+		finishDate    cli.DateValue
+		last          int = 10
+		outputPath    string
 	)
 
 	var flagset = flag.NewFlagSet("", flag.ExitOnError)
 	flagset.StringVar(&advisorName, "advisor", advisorName, "")
+	flagset.StringVar(&advisorParams, "params", advisorParams, "")
 	flagset.StringVar(&timeframeName, "timeframe", timeframeName, "")
 	flagset.StringVar(&securityName, "security", securityName, "")
+	flagset.Var(&startDate, "start", "")
+	flagset.Var(&finishDate, "finish", "")
+	flagset.IntVar(&last, "last", last, "")
+	flagset.StringVar(&outputPath, "output", outputPath, "")
 	flagset.Parse(args)
+	// за выбранный период по умолчанию показываются все изменения
+	var lastSet = false
+	flagset.Visit(func(f *flag.Flag) {
+		if f.Name == "last" {
+			lastSet = true
+		}
+	})
+	if !lastSet && (!startDate.Date.IsZero() || !finishDate.Date.IsZero()) {
+		last = 0
+	}
+
+	params, err := advisors.ParseParams(advisorParams)
+	if err != nil {
+		return err
+	}
 
 	var candleStorage = candles.NewCandleStorage(cli.MapPath("~/TradingData"), timeframeName, moex.TimeZone)
-	return history.AdvisorStatus(candleStorage, advisorName, securityName)
+	return history.AdvisorStatus(candleStorage, history.StatusSettings{
+		AdvisorName:   advisorName,
+		AdvisorParams: params,
+		SecurityName:  securityName,
+		Start:         startDate.Date,
+		Finish:        finishDate.Date,
+		Last:          last,
+		OutputPath:    outputPath,
+	})
 }
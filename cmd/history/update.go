@@ -49,8 +49,12 @@ func updateHandler(args []string) error {
 }
 
 func calcStartDate(securityCode string) time.Time {
-	// Для квартального фьючерса качаем за 4 месяца до примерной экспирации
-	return moex.ApproxExpirationDate(securityCode).AddDate(0, -4, 0)
+	// Для квартального фьючерса качаем за 4 месяца до экспирации
+	var expiration, err = moex.ExpirationDate(securityCode)
+	if err != nil {
+		return time.Time{}
+	}
+	return expiration.AddDate(0, -4, 0)
 }
 
 func checkPriceChange(x, y domain.Candle) error {
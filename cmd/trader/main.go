@@ -69,7 +69,12 @@ func run(
 	var candleStorage = candles.NewCandleStorageByPath(cli.MapPath("~/TradingData/Forts"), moex.TimeZone)
 	//var candleInterval = domain.CandleIntervalMinutes5
 	//var candleStorage = candles.NewCandleStorage(cli.MapPath("~/TradingData"), candleInterval, moex.TimeZone)
-	return trader.Run(logger, candleStorage, config)
+	// справочник инструментов рядом с trader.xml, если его нет - встроенный в программу
+	securityInformator, err := moex.LoadSecurityInformator("securities.xml")
+	if err != nil {
+		return err
+	}
+	return trader.Run(logger, candleStorage, config, securityInformator)
 }
 
 func buildLogFilePath(date time.Time) string {
@@ -0,0 +1,294 @@
+package advisors
+
+import (
+	"advisordev/internal/domain"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Тип параметра советника
+type ParamKind int
+
+const (
+	ParamInt ParamKind = iota
+	ParamFloat
+	ParamBool
+)
+
+func (k ParamKind) String() string {
+	switch k {
+	case ParamInt:
+		return "int"
+	case ParamFloat:
+		return "float"
+	case ParamBool:
+		return "bool"
+	default:
+		return fmt.Sprintf("ParamKind(%d)", int(k))
+	}
+}
+
+// Описание параметра советника
+type ParamSpec struct {
+	Name    string
+	Kind    ParamKind
+	Default float64
+	// Допустимый диапазон. Не проверяется, если Min == Max.
+	Min         float64
+	Max         float64
+	Description string
+}
+
+func (spec ParamSpec) Validate(value float64) error {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Errorf("param %v: bad value %v", spec.Name, value)
+	}
+	if spec.Kind == ParamInt && value != math.Trunc(value) {
+		return fmt.Errorf("param %v: int expected %v", spec.Name, value)
+	}
+	if spec.Kind == ParamBool && value != 0 && value != 1 {
+		return fmt.Errorf("param %v: bool expected %v", spec.Name, value)
+	}
+	if spec.Min != spec.Max && (value < spec.Min || value > spec.Max) {
+		return fmt.Errorf("param %v: %v out of range [%v, %v]", spec.Name, value, spec.Min, spec.Max)
+	}
+	return nil
+}
+
+// Значения параметров советника по имени
+type Params map[string]float64
+
+func (p Params) Int(name string) int {
+	return int(math.Round(p[name]))
+}
+
+func (p Params) Float(name string) float64 {
+	return p[name]
+}
+
+func (p Params) Bool(name string) bool {
+	return p[name] != 0
+}
+
+func (p Params) Clone() Params {
+	var result = make(Params, len(p))
+	for key, value := range p {
+		result[key] = value
+	}
+	return result
+}
+
+// Sample: "fast=20,slow=100"
+func (p Params) String() string {
+	var names = make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var parts = make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + strconv.FormatFloat(p[name], 'g', -1, 64)
+	}
+	return strings.Join(parts, ",")
+}
+
+// Разбирает строку вида "fast=20,slow=100". Значения проверяются отдельно по описанию советника.
+func ParseParams(s string) (Params, error) {
+	var result = make(Params)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, found := strings.Cut(item, "=")
+		if !found {
+			return nil, fmt.Errorf("bad param %q", item)
+		}
+		v, err := ParseParamValue(value)
+		if err != nil {
+			return nil, err
+		}
+		result[strings.TrimSpace(name)] = v
+	}
+	return result, nil
+}
+
+func ParseParamValue(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	switch s {
+	case "true":
+		return 1, nil
+	case "false":
+		return 0, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad param value %q", s)
+	}
+	return v, nil
+}
+
+// Создает новый экземпляр советника. Советник хранит состояние, поэтому для каждого прогона нужен свой экземпляр.
+type Factory func(params Params, logger *slog.Logger) domain.Advisor
+
+type AdvisorInfo struct {
+	Name        string
+	Description string
+	Params      []ParamSpec
+	Factory     Factory
+}
+
+func (info AdvisorInfo) DefaultParams() Params {
+	var result = make(Params, len(info.Params))
+	for _, param := range info.Params {
+		result[param.Name] = param.Default
+	}
+	return result
+}
+
+func (info AdvisorInfo) Param(name string) (ParamSpec, bool) {
+	for _, param := range info.Params {
+		if param.Name == name {
+			return param, true
+		}
+	}
+	return ParamSpec{}, false
+}
+
+// Параметры по умолчанию, переопределенные params.
+func (info AdvisorInfo) ResolveParams(params Params) (Params, error) {
+	var result = info.DefaultParams()
+	var names = make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		spec, found := info.Param(name)
+		if !found {
+			return nil, fmt.Errorf("advisor %v: unknown param %v", info.Name, name)
+		}
+		var err = spec.Validate(params[name])
+		if err != nil {
+			return nil, fmt.Errorf("advisor %v: %w", info.Name, err)
+		}
+		result[name] = params[name]
+	}
+	return result, nil
+}
+
+var registry = make(map[string]AdvisorInfo)
+
+// Вызывается из init. Повторная регистрация имени - ошибка программиста.
+func Register(info AdvisorInfo) {
+	if info.Name == "" || info.Factory == nil {
+		panic("advisors: bad registration")
+	}
+	if _, found := registry[info.Name]; found {
+		panic("advisors: advisor already registered " + info.Name)
+	}
+	registry[info.Name] = info
+}
+
+func Lookup(name string) (AdvisorInfo, error) {
+	var info, found = registry[name]
+	if !found {
+		return AdvisorInfo{}, fmt.Errorf("advisor not found %q", name)
+	}
+	return info, nil
+}
+
+// Зарегистрированные советники в порядке имен
+func List() []AdvisorInfo {
+	var result = make([]AdvisorInfo, 0, len(registry))
+	for _, info := range registry {
+		result = append(result, info)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// Проверяет параметры один раз и возвращает функцию создания экземпляров советника.
+func Prepare(name string, params Params) (func(logger *slog.Logger) domain.Advisor, error) {
+	info, err := Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	actualParams, err := info.ResolveParams(params)
+	if err != nil {
+		return nil, err
+	}
+	return func(logger *slog.Logger) domain.Advisor {
+		if logger == nil {
+			logger = discardLogger
+		}
+		var advisor = info.Factory(actualParams.Clone(), logger)
+		return WithName(advisor, name)
+	}, nil
+}
+
+func New(name string, params Params, logger *slog.Logger) (domain.Advisor, error) {
+	build, err := Prepare(name, params)
+	if err != nil {
+		return nil, err
+	}
+	return build(logger), nil
+}
+
+// Параметр, в который передается StdVolatility из настроек сигнала
+const StdVolatilityParam = "stdvolatility"
+
+func MainAdvisor(name string, params Params, stdVolatility float64, logger *slog.Logger) (domain.Advisor, error) {
+	return New(name, WithStdVolatility(name, params, stdVolatility), logger)
+}
+
+// Добавляет StdVolatility из настроек сигнала, если советник name объявляет параметр StdVolatilityParam
+// и параметр не задан явно. Советникам без параметра StdVolatility не передается.
+func WithStdVolatility(name string, params Params, stdVolatility float64) Params {
+	if stdVolatility == 0 {
+		return params
+	}
+	info, err := Lookup(name)
+	if err != nil {
+		return params
+	}
+	if _, declared := info.Param(StdVolatilityParam); !declared {
+		return params
+	}
+	if _, found := params[StdVolatilityParam]; !found {
+		params = params.Clone()
+		params[StdVolatilityParam] = stdVolatility
+	}
+	return params
+}
+
+// Для тестирования на истории советник создается заново для каждого контракта.
+func TestAdvisor(name string, params Params) (func() domain.Advisor, error) {
+	build, err := Prepare(name, params)
+	if err != nil {
+		return nil, err
+	}
+	return func() domain.Advisor {
+		return build(nil)
+	}, nil
+}
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// Стратегии находят свои сигналы по имени советника, поэтому имя проставляем здесь, а не в каждом советнике.
+func WithName(advisor domain.Advisor, name string) domain.Advisor {
+	return func(c domain.Candle) domain.Advice {
+		var advice = advisor(c)
+		if !advice.DateTime.IsZero() {
+			advice.Advisor = name
+		}
+		return advice
+	}
+}
@@ -0,0 +1,101 @@
+package advisors
+
+import (
+	"advisordev/internal/domain"
+	"log/slog"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestResolveParams(t *testing.T) {
+	info, err := Lookup("ma")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		params string
+		fast   float64
+		valid  bool
+	}{
+		{params: "", fast: 50, valid: true},
+		{params: "fast=20", fast: 20, valid: true},
+		{params: "fast=20, stdvolatility=0.006", fast: 20, valid: true},
+		{params: "fast=1.5", valid: false},
+		{params: "fast=0", valid: false},
+		{params: "period=20", valid: false},
+	}
+	for _, test := range tests {
+		params, err := ParseParams(test.params)
+		if err != nil {
+			t.Error(test, err)
+			continue
+		}
+		resolved, err := info.ResolveParams(params)
+		if (err == nil) != test.valid {
+			t.Error(test, err)
+			continue
+		}
+		if test.valid && resolved["fast"] != test.fast {
+			t.Error(test, resolved)
+		}
+	}
+}
+
+func TestWithStdVolatility(t *testing.T) {
+	// советник без параметра stdvolatility
+	if _, err := Lookup("test-plain"); err != nil {
+		Register(AdvisorInfo{
+			Name: "test-plain",
+			Factory: func(params Params, logger *slog.Logger) domain.Advisor {
+				return sampleAdvisor()
+			},
+		})
+	}
+	var tests = []struct {
+		name     string
+		params   Params
+		expected float64
+		found    bool
+	}{
+		{"ma", Params{}, 0.01, true},
+		{"ma", Params{StdVolatilityParam: 0.02}, 0.02, true},
+		{"main", Params{}, 0.01, true},
+		{"test-plain", Params{}, 0, false},
+	}
+	for _, test := range tests {
+		var params = WithStdVolatility(test.name, test.params, 0.01)
+		var value, found = params[StdVolatilityParam]
+		if value != test.expected || found != test.found {
+			t.Error(test, params)
+		}
+		if _, err := MainAdvisor(test.name, test.params, 0.01, nil); err != nil {
+			t.Error(test, err)
+		}
+	}
+}
+
+func TestWithVolatilityTarget(t *testing.T) {
+	var start = time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	var constant = func(c domain.Candle) domain.Advice {
+		return domain.Advice{SecurityCode: c.SecurityCode, DateTime: c.DateTime, Price: c.ClosePrice, Position: 1}
+	}
+	var advisor = withVolatilityTarget(constant, 0.01)
+	var advice domain.Advice
+	// цена дневного закрытия поочередно растет и падает на 2%
+	var price = 100.0
+	for i := 0; i < 40; i++ {
+		if i%2 == 0 {
+			price *= 1.02
+		} else {
+			price /= 1.02
+		}
+		advice = advisor(domain.Candle{DateTime: start.AddDate(0, 0, i), ClosePrice: price})
+		if i < 20 && !advice.DateTime.IsZero() {
+			t.Fatal("advice before volatility", i, advice)
+		}
+	}
+	if math.Abs(advice.Position-0.01/math.Log(1.02)) > 1e-6 {
+		t.Error(advice)
+	}
+}
@@ -3,16 +3,36 @@ package advisors
 import (
 	"advisordev/internal/domain"
 	"log/slog"
+	"math"
+	"time"
 )
 
-func MainAdvisor(name string, stdVolatility float64, logger *slog.Logger) domain.Advisor {
-	var advisor = sampleAdvisor()
-	return advisor
+var stdVolatilityParamSpec = ParamSpec{
+	Name: StdVolatilityParam, Kind: ParamFloat, Default: 0, Min: 0, Max: 1,
+	Description: "Целевое дневное стандартное отклонение позиции (0 - без нормировки)",
 }
 
-func TestAdvisor(name string) domain.Advisor {
-	var advisor = sampleAdvisor()
-	return advisor
+func init() {
+	Register(AdvisorInfo{
+		Name:        "main",
+		Description: "Пример основного советника: всегда вне рынка",
+		Params:      []ParamSpec{stdVolatilityParamSpec},
+		Factory: func(params Params, logger *slog.Logger) domain.Advisor {
+			return withVolatilityTarget(sampleAdvisor(), params.Float(StdVolatilityParam))
+		},
+	})
+	Register(AdvisorInfo{
+		Name:        "ma",
+		Description: "Пересечение быстрой и медленной экспоненциальных средних",
+		Params: []ParamSpec{
+			{Name: "fast", Kind: ParamInt, Default: 50, Min: 1, Max: 10_000, Description: "Период быстрой средней (бары)"},
+			{Name: "slow", Kind: ParamInt, Default: 200, Min: 1, Max: 10_000, Description: "Период медленной средней (бары)"},
+			stdVolatilityParamSpec,
+		},
+		Factory: func(params Params, logger *slog.Logger) domain.Advisor {
+			return withVolatilityTarget(maAdvisor(params.Int("fast"), params.Int("slow")), params.Float(StdVolatilityParam))
+		},
+	})
 }
 
 func sampleAdvisor() domain.Advisor {
@@ -26,3 +46,101 @@ func sampleAdvisor() domain.Advisor {
 		}
 	}
 }
+
+func maAdvisor(fastPeriod, slowPeriod int) domain.Advisor {
+	type MaDetails struct {
+		Name string
+		Fast float64
+		Slow float64
+	}
+
+	var fast = newEma(fastPeriod)
+	var slow = newEma(slowPeriod)
+	var count = 0
+	return func(c domain.Candle) domain.Advice {
+		var fastValue = fast(c.ClosePrice)
+		var slowValue = slow(c.ClosePrice)
+		count++
+		if count < slowPeriod {
+			return domain.Advice{}
+		}
+		var position = 0.0
+		if fastValue > slowValue {
+			position = 1
+		} else if fastValue < slowValue {
+			position = -1
+		}
+		return domain.Advice{
+			SecurityCode: c.SecurityCode,
+			DateTime:     c.DateTime,
+			Price:        c.ClosePrice,
+			Position:     position,
+			Details: MaDetails{
+				Name: "ma",
+				Fast: fastValue,
+				Slow: slowValue,
+			},
+		}
+	}
+}
+
+// Нормирует позицию советника на целевое дневное стандартное отклонение stdVolatility.
+// Если stdVolatility == 0, то советник не меняется. Пока волатильность не оценена, советов нет.
+func withVolatilityTarget(advisor domain.Advisor, stdVolatility float64) domain.Advisor {
+	if stdVolatility == 0 {
+		return advisor
+	}
+	var volatility = newDailyVolatility(20)
+	return func(c domain.Candle) domain.Advice {
+		var dayStDev = volatility(c)
+		var advice = advisor(c)
+		if advice.DateTime.IsZero() || dayStDev == 0 {
+			return domain.Advice{}
+		}
+		advice.Position *= stdVolatility / dayStDev
+		return advice
+	}
+}
+
+func newEma(period int) func(float64) float64 {
+	var k = 2.0 / float64(period+1)
+	var value = 0.0
+	var initialized = false
+	return func(x float64) float64 {
+		if !initialized {
+			value = x
+			initialized = true
+		} else {
+			value += k * (x - value)
+		}
+		return value
+	}
+}
+
+// Оценка стандартного отклонения дневной доходности по ценам закрытия дней.
+// Возвращает 0, пока не накоплено period дней.
+func newDailyVolatility(period int) func(domain.Candle) float64 {
+	var variance = newEma(period)
+	var days = 0
+	var lastDate time.Time
+	var dayClose, prevDayClose float64
+	var result = 0.0
+	return func(c domain.Candle) float64 {
+		var y, m, d = c.DateTime.Date()
+		var date = time.Date(y, m, d, 0, 0, 0, 0, c.DateTime.Location())
+		if !lastDate.IsZero() && !date.Equal(lastDate) {
+			if prevDayClose != 0 {
+				var r = math.Log(dayClose / prevDayClose)
+				var v = variance(r * r)
+				days++
+				if days >= period {
+					result = math.Sqrt(v)
+				}
+			}
+			prevDayClose = dayClose
+		}
+		lastDate = date
+		dayClose = c.ClosePrice
+		return result
+	}
+}
@@ -0,0 +1,65 @@
+package candles
+
+import (
+	"advisordev/internal/domain"
+	"iter"
+	"sync"
+)
+
+// Хранит прочитанные свечи в памяти, чтобы многократные прогоны (оптимизация) не читали файлы заново.
+// Потокобезопасный.
+type CachedCandleStorage struct {
+	storage domain.ICandleStorage
+	mu      sync.Mutex
+	items   map[string]*cachedCandles
+}
+
+type cachedCandles struct {
+	once    sync.Once
+	candles []domain.Candle
+	err     error
+}
+
+func NewCachedCandleStorage(storage domain.ICandleStorage) *CachedCandleStorage {
+	return &CachedCandleStorage{
+		storage: storage,
+		items:   make(map[string]*cachedCandles),
+	}
+}
+
+func (srv *CachedCandleStorage) Candles(
+	securityCode string,
+) iter.Seq2[domain.Candle, error] {
+	return func(yield func(domain.Candle, error) bool) {
+		var item = srv.item(securityCode)
+		item.once.Do(func() {
+			for candle, err := range srv.storage.Candles(securityCode) {
+				if err != nil {
+					item.err = err
+					return
+				}
+				item.candles = append(item.candles, candle)
+			}
+		})
+		if item.err != nil {
+			yield(domain.Candle{}, item.err)
+			return
+		}
+		for _, candle := range item.candles {
+			if !yield(candle, nil) {
+				return
+			}
+		}
+	}
+}
+
+func (srv *CachedCandleStorage) item(securityCode string) *cachedCandles {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	var item, found = srv.items[securityCode]
+	if !found {
+		item = &cachedCandles{}
+		srv.items[securityCode] = item
+	}
+	return item
+}
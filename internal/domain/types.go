@@ -47,6 +47,8 @@ type SecurityInfo struct {
 	PriceStep float64
 	// Стоимость шага цены
 	PriceStepCost float64
+	// Размер лота
+	Lot int
 	// Плечо. Для фьючерсов = PriceStepCost/PriceStep.
 	Lever float64
 }
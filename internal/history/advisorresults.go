@@ -10,17 +10,58 @@ import (
 func SingleContractHprs(
 	candles iter.Seq2[domain.Candle, error],
 	advisor domain.Advisor,
-	slippage float64,
+	cost CostModel,
 	skipPnl func(time.Time, time.Time) bool) ([]DateSum, error) {
 
-	var result []DateSum
+	days, _, err := singleContractDays(candles, advisor, cost, skipPnl)
+	if err != nil {
+		return nil, err
+	}
+	return contractDaysHprs(days), nil
+}
+
+// Итоги дня по контракту: доходность, позиция и цена на конец дня, объем торгов
+type contractDay struct {
+	DateSum
+	Position float64
+	Price    float64
+	Volume   float64
+}
+
+func contractDaysHprs(days []contractDay) []DateSum {
+	var result = make([]DateSum, len(days))
+	for i, day := range days {
+		result[i] = day.DateSum
+	}
+	return result
+}
+
+// Дневные итоги контракта и сделки советника за один проход по барам
+func singleContractDays(
+	candles iter.Seq2[domain.Candle, error],
+	advisor domain.Advisor,
+	cost CostModel,
+	skipPnl func(time.Time, time.Time) bool) ([]contractDay, []Trade, error) {
+
+	var result []contractDay
+	var trades tradeTracker
 	var pnl = 0.0
+	var volume = 0.0
 	var baseAdvice = domain.Advice{}
 	var lastAdvice = domain.Advice{}
 
+	var newDay = func() contractDay {
+		return contractDay{
+			DateSum:  DateSum{Date: dateTimeToDate(lastAdvice.DateTime), Sum: 1 + pnl/baseAdvice.Price},
+			Position: lastAdvice.Position,
+			Price:    lastAdvice.Price,
+			Volume:   volume,
+		}
+	}
+
 	for candle, err := range candles {
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		var advice = advisor(candle)
 		if advice.DateTime.IsZero() {
@@ -29,24 +70,37 @@ func SingleContractHprs(
 		if baseAdvice.DateTime.IsZero() {
 			baseAdvice = advice
 			lastAdvice = advice
+			volume = candle.Volume
+			trades.start(advice)
 			continue
 		}
 		if isNewFortsDateStarted(lastAdvice.DateTime, advice.DateTime) {
-			var ds = DateSum{Date: dateTimeToDate(lastAdvice.DateTime), Sum: 1 + pnl/baseAdvice.Price}
-			result = append(result, ds)
+			trades.endDay(len(result), lastAdvice)
+			result = append(result, newDay())
 			pnl = 0
+			volume = 0
 			baseAdvice = lastAdvice
 		}
-		if !skipPnl(lastAdvice.DateTime, advice.DateTime) {
-			pnl += lastAdvice.Position*(advice.Price-lastAdvice.Price) -
-				slippage*advice.Price*math.Abs(advice.Position-lastAdvice.Position)
+		volume += candle.Volume
+		var slippage = 0.0
+		if advice.Position != lastAdvice.Position {
+			slippage, err = cost(advice.SecurityCode, advice.DateTime, advice.Price)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		var skip = skipPnl(lastAdvice.DateTime, advice.DateTime)
+		if !skip {
+			pnl += lastAdvice.Position * (advice.Price - lastAdvice.Price)
+			pnl -= slippage * advice.Price * math.Abs(advice.Position-lastAdvice.Position)
 		}
+		trades.next(len(result), candle, advice, lastAdvice, slippage, skip)
 		lastAdvice = advice
 	}
 
 	if !lastAdvice.DateTime.IsZero() {
-		var ds = DateSum{Date: dateTimeToDate(lastAdvice.DateTime), Sum: 1 + pnl/baseAdvice.Price}
-		result = append(result, ds)
+		trades.finish(len(result), lastAdvice)
+		result = append(result, newDay())
 	}
-	return result, nil
+	return result, trades.trades, nil
 }
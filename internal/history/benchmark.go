@@ -0,0 +1,138 @@
+package history
+
+import (
+	"advisordev/internal/domain"
+	"advisordev/internal/moex"
+	"fmt"
+	"math"
+	"runtime"
+	"time"
+)
+
+// Сравнение доходностей советника с эталоном (купил и держи) по общим дням
+type BenchmarkStatistics struct {
+	SecurityName string
+	Days         int
+	TotalHpr     float64
+	AnnualHpr    float64
+	// Доходности эталона за те же дни
+	BenchmarkTotalHpr  float64
+	BenchmarkAnnualHpr float64
+	// Разница годовых доходностей советника и эталона
+	ExcessReturn float64
+	Correlation  float64
+	Beta         float64
+	// Годовое стандартное отклонение разницы дневных доходностей
+	TrackingError float64
+	// Годовая средняя разница дневных доходностей к TrackingError
+	InformationRatio float64
+	// Дневные доходности эталона без плеча
+	Hprs []DateSum
+}
+
+// Советник, который все время держит одну длинную позицию
+func buyAndHoldAdvisor() domain.Advisor {
+	return func(candle domain.Candle) domain.Advice {
+		return domain.Advice{
+			Advisor:      "buyandhold",
+			SecurityCode: candle.SecurityCode,
+			DateTime:     candle.DateTime,
+			Price:        candle.ClosePrice,
+			Position:     1,
+		}
+	}
+}
+
+// Доходности "купил и держи" по контрактам secCodes, без издержек
+func benchmarkHprs(candleStorage domain.ICandleStorage, secCodes []string, roll RollSettings, strict bool) ([]DateSum, error) {
+	return MultiContractHprs(
+		candleStorage, buyAndHoldAdvisor, secCodes, ConstantCost(0), roll, strict, moex.FortsCalendar.IsAfterHolidays, runtime.NumCPU())
+}
+
+func computeBenchmarkStatistics(securityName string, hprs, benchmark []DateSum) (BenchmarkStatistics, error) {
+	var benchmarkByDate = make(map[time.Time]float64, len(benchmark))
+	for _, hpr := range benchmark {
+		benchmarkByDate[hpr.Date] = hpr.Sum
+	}
+	var strategyHprs, benchmarkHprs []DateSum
+	for _, hpr := range hprs {
+		if sum, found := benchmarkByDate[hpr.Date]; found {
+			strategyHprs = append(strategyHprs, hpr)
+			benchmarkHprs = append(benchmarkHprs, DateSum{Date: hpr.Date, Sum: sum})
+		}
+	}
+	if len(strategyHprs) < 2 {
+		return BenchmarkStatistics{}, fmt.Errorf("benchmark %v: no common days", securityName)
+	}
+
+	var x = make([]float64, len(strategyHprs))
+	var y = make([]float64, len(strategyHprs))
+	var diff = make([]float64, len(strategyHprs))
+	for i := range strategyHprs {
+		x[i] = strategyHprs[i].Sum - 1
+		y[i] = benchmarkHprs[i].Sum - 1
+		diff[i] = x[i] - y[i]
+	}
+	var daysPerYear = tradingDaysPerYear(strategyHprs)
+
+	var result = BenchmarkStatistics{
+		SecurityName:       securityName,
+		Days:               len(strategyHprs),
+		TotalHpr:           totalHpr(strategyHprs),
+		AnnualHpr:          annualHpr(strategyHprs),
+		BenchmarkTotalHpr:  totalHpr(benchmarkHprs),
+		BenchmarkAnnualHpr: annualHpr(benchmarkHprs),
+		Correlation:        correlation(x, y),
+		Hprs:               benchmarkHprs,
+	}
+	result.ExcessReturn = result.AnnualHpr - result.BenchmarkAnnualHpr
+	var _, stDevX = moments(x)
+	var _, stDevY = moments(y)
+	if stDevY != 0 {
+		result.Beta = result.Correlation * stDevX / stDevY
+	}
+	var meanDiff, stDevDiff = moments(diff)
+	result.TrackingError = stDevDiff * math.Sqrt(daysPerYear)
+	if stDevDiff != 0 {
+		result.InformationRatio = meanDiff / stDevDiff * math.Sqrt(daysPerYear)
+	}
+	// корреляция не определена, если доходности одной из серий постоянны
+	if math.IsNaN(result.Correlation) {
+		result.Correlation = 0
+	}
+	return result, nil
+}
+
+func benchmarkTable(stat BenchmarkStatistics) reportTable {
+	return reportTable{
+		Title:  "Сравнение с " + stat.SecurityName,
+		Header: []string{"", "Советник", "Эталон"},
+		Rows: [][]string{
+			{"Дней", fmt.Sprint(stat.Days), fmt.Sprint(stat.Days)},
+			{"Доходность", fmt.Sprintf("%.1f%%", hprPercent(stat.TotalHpr)), fmt.Sprintf("%.1f%%", hprPercent(stat.BenchmarkTotalHpr))},
+			{"Годовая доходность", fmt.Sprintf("%.1f%%", hprPercent(stat.AnnualHpr)), fmt.Sprintf("%.1f%%", hprPercent(stat.BenchmarkAnnualHpr))},
+			{"Превышение годовой доходности", fmt.Sprintf("%.1f%%", stat.ExcessReturn*100), ""},
+			{"Корреляция", fmt.Sprintf("%.2f", stat.Correlation), ""},
+			{"Бета", fmt.Sprintf("%.2f", stat.Beta), ""},
+			{"Ошибка слежения", fmt.Sprintf("%.1f%%", stat.TrackingError*100), ""},
+			{"Информационный коэффициент", fmt.Sprintf("%.2f", stat.InformationRatio), ""},
+		},
+	}
+}
+
+// Эквити эталона на даты доходностей советника. В дни без данных эталона эквити не меняется.
+func benchmarkEquity(dates []DateSum, benchmark []DateSum) []float64 {
+	var byDate = make(map[time.Time]float64, len(benchmark))
+	for _, hpr := range benchmark {
+		byDate[hpr.Date] = hpr.Sum
+	}
+	var result = make([]float64, len(dates))
+	var equity = 1.0
+	for i, item := range dates {
+		if sum, found := byDate[item.Date]; found {
+			equity *= sum
+		}
+		result[i] = equity
+	}
+	return result
+}
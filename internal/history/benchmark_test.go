@@ -0,0 +1,24 @@
+package history
+
+import (
+	"math"
+	"testing"
+)
+
+func TestComputeBenchmarkStatistics(t *testing.T) {
+	var benchmark = testHprs(1.01, 0.98, 1.02, 0.99, 1.03)
+	var hprs = hprsWithLever(benchmark, 2)
+	// дни без эталона не учитываются
+	hprs = append(hprs, DateSum{Date: hprs[len(hprs)-1].Date.AddDate(0, 0, 1), Sum: 1.5})
+
+	stat, err := computeBenchmarkStatistics("Si", hprs, benchmark)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Days != len(benchmark) ||
+		math.Abs(stat.Correlation-1) > 1e-9 ||
+		math.Abs(stat.Beta-2) > 1e-9 ||
+		math.Abs(stat.BenchmarkTotalHpr-totalHpr(benchmark)) > 1e-9 {
+		t.Error(stat)
+	}
+}
@@ -0,0 +1,28 @@
+package history
+
+import (
+	"advisordev/internal/moex"
+	"errors"
+	"time"
+)
+
+// Издержки на изменение позиции на единицу в долях цены: комиссии и проскальзывание
+type CostModel func(securityCode string, dateTime time.Time, price float64) (float64, error)
+
+// Одинаковые издержки для всех инструментов и периодов
+func ConstantCost(cost float64) CostModel {
+	return func(securityCode string, dateTime time.Time, price float64) (float64, error) {
+		return cost, nil
+	}
+}
+
+// Издержки по тарифам, действовавшим на дату сделки. До первого тарифа - постоянные издержки beforeTariffs.
+func FeeCost(fees *moex.FeeModel, beforeTariffs float64) CostModel {
+	return func(securityCode string, dateTime time.Time, price float64) (float64, error) {
+		var cost, err = fees.Cost(securityCode, dateTime, price)
+		if errors.Is(err, moex.ErrTariffNotFound) {
+			return beforeTariffs, nil
+		}
+		return cost, err
+	}
+}
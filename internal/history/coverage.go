@@ -0,0 +1,98 @@
+package history
+
+import (
+	"advisordev/internal/moex"
+	"fmt"
+	"time"
+)
+
+// Данные контракта: первый и последний день. Error - ошибка загрузки или тестирования контракта.
+type ContractCoverage struct {
+	SecurityCode string
+	First        time.Time
+	Last         time.Time
+	Days         int
+	Error        string `json:",omitempty"`
+}
+
+// Период без данных в склеенных доходностях (даты включительно)
+type DateSpan struct {
+	From time.Time
+	To   time.Time
+}
+
+// Сколько торговых дней по календарю FORTS подряд может не быть данных
+const maxMissingTradingDays = 2
+
+func contractCoverage(secCodes []string, daysByContracts [][]contractDay, errs []error) []ContractCoverage {
+	var result = make([]ContractCoverage, len(secCodes))
+	for i, securityCode := range secCodes {
+		result[i].SecurityCode = securityCode
+		if errs[i] != nil {
+			result[i].Error = errs[i].Error()
+			continue
+		}
+		var days = daysByContracts[i]
+		result[i].Days = len(days)
+		if len(days) == 0 {
+			continue
+		}
+		result[i].First = days[0].Date
+		result[i].Last = days[len(days)-1].Date
+	}
+	return result
+}
+
+// Промежутки между днями доходностей, в которых больше maxMissingTradingDays торговых дней без данных
+func missingSpans(hprs []DateSum) []DateSpan {
+	var result []DateSpan
+	for i := 1; i < len(hprs); i++ {
+		var from = hprs[i-1].Date.AddDate(0, 0, 1)
+		var to = hprs[i].Date.AddDate(0, 0, -1)
+		var tradingDays = 0
+		for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+			if moex.FortsCalendar.IsTradingDay(d) {
+				tradingDays++
+			}
+		}
+		if tradingDays > maxMissingTradingDays {
+			result = append(result, DateSpan{From: from, To: to})
+		}
+	}
+	return result
+}
+
+// Первая ошибка контракта, для строгого режима
+func contractsError(coverage []ContractCoverage) error {
+	for _, item := range coverage {
+		if item.Error != "" {
+			return fmt.Errorf("contract %v: %v", item.SecurityCode, item.Error)
+		}
+	}
+	return nil
+}
+
+func coverageTable(coverage []ContractCoverage, missing []DateSpan) reportTable {
+	var rows [][]string
+	for _, item := range coverage {
+		if item.Error != "" || item.Days == 0 {
+			rows = append(rows, []string{item.SecurityCode, "", "", fmt.Sprint(item.Days), item.Error})
+			continue
+		}
+		rows = append(rows, []string{
+			item.SecurityCode,
+			item.First.Format("02.01.2006"),
+			item.Last.Format("02.01.2006"),
+			fmt.Sprint(item.Days),
+			"",
+		})
+	}
+	for _, span := range missing {
+		rows = append(rows, []string{"нет данных", span.From.Format("02.01.2006"), span.To.Format("02.01.2006"), "", ""})
+	}
+	return reportTable{
+		Title:  "Контракты",
+		Header: []string{"Контракт", "Первый день", "Последний день", "Дней", "Ошибка"},
+		Rows:   rows,
+	}
+}
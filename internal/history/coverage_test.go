@@ -0,0 +1,35 @@
+package history
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestContractCoverage(t *testing.T) {
+	var date = func(month time.Month, day int) time.Time {
+		return time.Date(2025, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	var secCodes = []string{"Si-3.25", "Si-6.25", "Si-9.25"}
+	var daysByContracts = [][]contractDay{
+		{{DateSum: DateSum{Date: date(3, 3)}}, {DateSum: DateSum{Date: date(3, 14)}}},
+		nil,
+		{{DateSum: DateSum{Date: date(6, 2)}}},
+	}
+	var errs = []error{nil, errors.New("no file"), nil}
+	var coverage = contractCoverage(secCodes, daysByContracts, errs)
+	if coverage[0].Days != 2 || !coverage[0].First.Equal(date(3, 3)) || !coverage[0].Last.Equal(date(3, 14)) ||
+		coverage[1].Error != "no file" || coverage[2].Days != 1 {
+		t.Errorf("contractCoverage %+v", coverage)
+	}
+	if err := contractsError(coverage); err == nil {
+		t.Error("contractsError: expected error for Si-6.25")
+	}
+
+	// пятница 14.03 -> понедельник 17.03 без пропуска; 17.03 -> 02.06 пропуск
+	var hprs = []DateSum{{Date: date(3, 14)}, {Date: date(3, 17)}, {Date: date(6, 2)}, {Date: date(6, 3)}}
+	var missing = missingSpans(hprs)
+	if len(missing) != 1 || !missing[0].From.Equal(date(3, 18)) || !missing[0].To.Equal(date(6, 1)) {
+		t.Errorf("missingSpans %+v", missing)
+	}
+}
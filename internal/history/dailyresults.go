@@ -6,6 +6,7 @@ import (
 	"math"
 	"os"
 	"sort"
+	"strings"
 	"text/tabwriter"
 	"time"
 )
@@ -20,8 +21,19 @@ type DateSum struct {
 
 type HprStatistcs struct {
 	MonthHpr           float64
+	AnnualHpr          float64
 	StDev              float64
 	AVaR               float64
+	CVaR               []CVaR
+	Sharpe             float64
+	Sortino            float64
+	Calmar             float64
+	RecoveryFactor     float64
+	UlcerIndex         float64
+	Skewness           float64
+	Kurtosis           float64
+	ProfitableDays     float64
+	ProfitableMonths   float64
 	DayHprs            []DateSum
 	MonthHprs          []DateSum
 	YearHprs           []DateSum
@@ -39,11 +51,11 @@ type DrawdownInfo struct {
 }
 
 func ReportDailyResults(dailyResults []DateSum) {
-	var stat = computeHprStatistcs(dailyResults)
-	printHprReport(stat)
+	var stat = computeHprStatistcs(dailyResults, defaultCVaRLevels)
+	printHprReport(os.Stdout, stat)
 }
 
-func computeHprStatistcs(hprs []DateSum) HprStatistcs {
+func computeHprStatistcs(hprs []DateSum, cvarLevels []float64) HprStatistcs {
 	var report = HprStatistcs{}
 	report.DayHprs = hprs
 	report.MonthHprs = hprsByPeriod(hprs, firstDayOMonth)
@@ -51,13 +63,24 @@ func computeHprStatistcs(hprs []DateSum) HprStatistcs {
 	report.MonthHpr = math.Pow(totalHpr(hprs), 22.0/float64(len(hprs)))
 	report.StDev = stDevHprs(hprs)
 	report.DrawdownInfo = computeDrawdownInfo(hprs)
+	report.AnnualHpr = annualHpr(hprs)
+	report.Sharpe = sharpeRatio(hprs)
+	report.Sortino = sortinoRatio(hprs)
+	report.Calmar = calmarRatio(hprs, report.DrawdownInfo.MaxDrawdown)
+	report.RecoveryFactor = recoveryFactor(hprs, report.DrawdownInfo.MaxDrawdown)
+	report.UlcerIndex = ulcerIndex(hprs)
+	report.Skewness, report.Kurtosis = skewnessKurtosis(logHprs(hprs))
+	report.ProfitableDays = profitableShare(hprs)
+	report.ProfitableMonths = profitableShare(report.MonthHprs)
 
 	var sortedHprs = make([]DateSum, len(hprs))
 	copy(sortedHprs, hprs)
 	sort.Slice(sortedHprs, func(i, j int) bool {
 		return sortedHprs[i].Sum < sortedHprs[j].Sum
 	})
-	report.AVaR = meanBySum(sortedHprs[:len(sortedHprs)/20])
+	// на коротком периоде хотя бы один худший день, иначе среднее не определено
+	report.AVaR = meanBySum(sortedHprs[:max(1, len(sortedHprs)/20)])
+	report.CVaR = computeCVaR(sortedHprs, cvarLevels)
 	report.ProfitableRating = sortedHprs[max(0, len(sortedHprs)-10):]
 	report.UnprofitableRating = sortedHprs[:min(len(sortedHprs), 10)]
 
@@ -135,49 +158,88 @@ func meanBySum(hprs []DateSum) float64 {
 	return mean
 }
 
-func printHprReport(report HprStatistcs) {
-	var w = newTabWriter()
-	fmt.Fprintf(w, "Ежемесячная доходность\t%.1f%%\t\n", (report.MonthHpr-1)*100)
-	fmt.Fprintf(w, "Среднеквадратичное отклонение доходности за день\t%.1f%%\t\n", report.StDev*100)
-	fmt.Fprintf(w, "Средний убыток в день среди 5%% худших дней\t%.1f%%\t\n", (report.AVaR-1)*100)
-	printDrawdownInfo(w, report.DrawdownInfo)
-	w.Flush()
+// Таблица отчета. Общая для текстового и html отчетов.
+type reportTable struct {
+	Title  string
+	Header []string
+	Rows   [][]string
+}
 
-	fmt.Println("Доходности по дням")
-	printHprs(report.DayHprs[max(0, len(report.DayHprs)-20):])
+func hprSummaryTable(report HprStatistcs) reportTable {
+	var info = report.DrawdownInfo
+	var rows = [][]string{
+		{"Ежемесячная доходность", fmt.Sprintf("%.1f%%", hprPercent(report.MonthHpr))},
+		{"Годовая доходность", fmt.Sprintf("%.1f%%", hprPercent(report.AnnualHpr))},
+		{"Среднеквадратичное отклонение доходности за день", fmt.Sprintf("%.1f%%", report.StDev*100)},
+		{"Средний убыток в день среди 5% худших дней", fmt.Sprintf("%.1f%%", hprPercent(report.AVaR))},
+	}
+	for _, item := range report.CVaR {
+		rows = append(rows, []string{
+			fmt.Sprintf("CVaR %.4g%%", item.Level*100), fmt.Sprintf("%.1f%%", hprPercent(item.Hpr))})
+	}
+	rows = append(rows,
+		[]string{"Максимальная просадка", fmt.Sprintf("%.1f%%", hprPercent(info.MaxDrawdown))},
+		[]string{"Продолжительная просадка", fmt.Sprintf("%v дн.", info.LongestDrawdown)},
+		[]string{"Текущая просадка", fmt.Sprintf("%.1f%% %v дн.", hprPercent(info.CurrentDrawdown), info.CurrentDrawdownDays)},
+		[]string{"Дата максимума эквити", info.HighEquityDate.Format(dateFormatLayout)},
+		[]string{"Коэффициент Шарпа", fmt.Sprintf("%.2f", report.Sharpe)},
+		[]string{"Коэффициент Сортино", fmt.Sprintf("%.2f", report.Sortino)},
+		[]string{"Коэффициент Кальмара", fmt.Sprintf("%.2f", report.Calmar)},
+		[]string{"Фактор восстановления", fmt.Sprintf("%.2f", report.RecoveryFactor)},
+		[]string{"Индекс Ulcer", fmt.Sprintf("%.1f%%", report.UlcerIndex*100)},
+		[]string{"Асимметрия", fmt.Sprintf("%.2f", report.Skewness)},
+		[]string{"Эксцесс", fmt.Sprintf("%.2f", report.Kurtosis)},
+		[]string{"Прибыльных дней", fmt.Sprintf("%.1f%%", report.ProfitableDays*100)},
+		[]string{"Прибыльных месяцев", fmt.Sprintf("%.1f%%", report.ProfitableMonths*100)},
+	)
+	return reportTable{Rows: rows}
+}
 
-	fmt.Println("Доходности по месяцам")
-	printHprs(report.MonthHprs)
+func printReportTable(w io.Writer, table reportTable) {
+	if table.Title != "" {
+		fmt.Fprintln(w, table.Title)
+	}
+	var tw = newTabWriter(w)
+	if len(table.Header) != 0 {
+		fmt.Fprintln(tw, strings.Join(table.Header, "\t")+"\t")
+	}
+	for _, row := range table.Rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t")+"\t")
+	}
+	tw.Flush()
+}
+
+func printHprReport(w io.Writer, report HprStatistcs) {
+	printReportTable(w, hprSummaryTable(report))
+
+	fmt.Fprintln(w, "Доходности по дням")
+	printHprs(w, report.DayHprs[max(0, len(report.DayHprs)-20):])
 
-	fmt.Println("Доходности по годам")
-	printHprs(report.YearHprs)
+	fmt.Fprintln(w, "Доходности по месяцам")
+	printHprs(w, report.MonthHprs)
 
-	fmt.Println("Самые прибыльные дни")
-	printHprs(report.ProfitableRating)
+	fmt.Fprintln(w, "Доходности по годам")
+	printHprs(w, report.YearHprs)
 
-	fmt.Println("Самые убыточные дни")
-	printHprs(report.UnprofitableRating)
+	fmt.Fprintln(w, "Самые прибыльные дни")
+	printHprs(w, report.ProfitableRating)
+
+	fmt.Fprintln(w, "Самые убыточные дни")
+	printHprs(w, report.UnprofitableRating)
 }
 
-func newTabWriter() *tabwriter.Writer {
-	return tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.AlignRight)
+func newTabWriter(w io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(w, 0, 0, 1, ' ', tabwriter.AlignRight)
 }
 
 func hprPercent(hpr float64) float64 {
 	return (hpr - 1) * 100
 }
 
-func printDrawdownInfo(w io.Writer, info DrawdownInfo) {
-	fmt.Fprintf(w, "Максимальная просадка\t%.1f%%\t\n", hprPercent(info.MaxDrawdown))
-	fmt.Fprintf(w, "Продолжительная просадка\t%v дн.\t\n", info.LongestDrawdown)
-	fmt.Fprintf(w, "Текущая просадка\t%.1f%% %v дн.\t\n", hprPercent(info.CurrentDrawdown), info.CurrentDrawdownDays)
-	fmt.Fprintf(w, "Дата максимума эквити\t%v\t\n", info.HighEquityDate.Format(dateFormatLayout))
-}
-
-func printHprs(source []DateSum) {
-	var w = newTabWriter()
+func printHprs(w io.Writer, source []DateSum) {
+	var tw = newTabWriter(w)
 	for _, item := range source {
-		fmt.Fprintf(w, "%v\t%.1f%%\t\n", item.Date.Format(dateFormatLayout), (item.Sum-1)*100)
+		fmt.Fprintf(tw, "%v\t%.1f%%\t\n", item.Date.Format(dateFormatLayout), (item.Sum-1)*100)
 	}
-	w.Flush()
+	tw.Flush()
 }
@@ -19,7 +19,7 @@ func dateTimeToDate(t time.Time) time.Time {
 }
 
 func isNewFortsDateStarted(l, r time.Time) bool {
-	return moex.IsMainFortsSession(l) && (!moex.IsMainFortsSession(r) || isNewDayStarted(l, r))
+	return isMainFortsSession(l) && (!isMainFortsSession(r) || isNewDayStarted(l, r))
 }
 
 func isNewDayStarted(l, r time.Time) bool {
@@ -27,23 +27,6 @@ func isNewDayStarted(l, r time.Time) bool {
 		r.After(l)
 }
 
-func isAfterLongHolidays(l, r time.Time) bool {
-	if !isNewDayStarted(l, r) {
-		return false
-	}
-	y, m, d := l.Date()
-	if y == 2022 && m == time.February && d == 25 {
-		// приостановка торгов из-за СВО. выйти заранее невозможно!
-		return false
-	}
-	var startDate = dateTimeToDate(l).AddDate(0, 0, 1)
-	var endDate = dateTimeToDate(r)
-	for d := startDate; d.Before(endDate); d = d.AddDate(0, 0, 1) {
-		var weekDay = d.Weekday()
-		if weekDay != time.Sunday && weekDay != time.Saturday {
-			//В промежутке между currentDate и nextDate был 1 не выходной (значит торговала Америка)
-			return true
-		}
-	}
-	return false
+func isMainFortsSession(d time.Time) bool {
+	return moex.FortsCalendar.Session(d) == moex.SessionMain
 }
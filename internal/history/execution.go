@@ -0,0 +1,341 @@
+package history
+
+import (
+	"advisordev/internal/domain"
+	"fmt"
+	"iter"
+	"log"
+	"math"
+	"time"
+)
+
+const (
+	// Исполнение по цене открытия следующего бара
+	FillNextOpen = "open"
+	// Лимитная заявка по цене сигнала с отступом, как в trader.priceWithSlippage.
+	// Исполняется, только если следующий бар торговался за лимитной ценой.
+	FillLimit = "limit"
+)
+
+type ExecutionSettings struct {
+	// Если 0, то симуляция исполнения не выполняется
+	Capital float64
+	Fill    string
+	// Отступ лимитной цены от цены сигнала (доля цены)
+	LimitSlippage float64
+}
+
+// Как trader.priceWithSlippage, с настраиваемым отступом. Цена округляется до шага цены, как в quik.formatPrice.
+func limitPrice(price float64, volume int, slippage float64, priceStep float64) float64 {
+	if volume > 0 {
+		return roundPrice(price*(1+slippage), priceStep)
+	}
+	return roundPrice(price*(1-slippage), priceStep)
+}
+
+func roundPrice(price, priceStep float64) float64 {
+	return math.Round(price/priceStep) * priceStep
+}
+
+// Стоимость изменения цены на diff для одного контракта: целое число шагов цены по PriceStepCost
+func priceStepsCost(diff float64, security domain.SecurityInfo) float64 {
+	return math.Round(diff/security.PriceStep) * security.PriceStepCost
+}
+
+type ExecutionResult struct {
+	// Дневные доходности на капитал с учетом целого числа контрактов и фактических цен исполнения
+	Hprs   []DateSum
+	Orders int
+	Fills  int
+	// Лимитные заявки, не исполненные на следующем баре
+	MissedFills int
+	// Сигналы, у которых дробная позиция ненулевая, а целая нулевая
+	ZeroPositions int
+	// Среднее по сигналам отклонение целой позиции от дробной, в контрактах
+	AvgRounding float64
+	// Средний сдвиг цены исполнения от цены сигнала против нас (доля цены)
+	AvgFillSlippage float64
+	// Покрытие контрактов, как у идеальных доходностей
+	Coverage []ContractCoverage `json:",omitempty"`
+	Missing  []DateSpan         `json:",omitempty"`
+
+	// Дневные итоги для склейки контрактов: позиция - стоимость контрактов в долях капитала
+	days          []contractDay
+	signals       int
+	roundings     float64
+	fillSlippages float64
+}
+
+// Счетчики суммируются по всем барам контрактов, в том числе не вошедшим в склеенные доходности
+func (r *ExecutionResult) add(other ExecutionResult) {
+	r.Orders += other.Orders
+	r.Fills += other.Fills
+	r.MissedFills += other.MissedFills
+	r.ZeroPositions += other.ZeroPositions
+	r.roundings += other.roundings
+	r.signals += other.signals
+	r.fillSlippages += other.fillSlippages
+}
+
+func (r *ExecutionResult) finish() {
+	if r.signals != 0 {
+		r.AvgRounding = r.roundings / float64(r.signals)
+	}
+	if r.Fills != 0 {
+		r.AvgFillSlippage = r.fillSlippages / float64(r.Fills)
+	}
+}
+
+type pendingOrder struct {
+	volume      int
+	signalPrice float64
+	limit       float64
+}
+
+// Симуляция торговли, как у trader.StrategyService: целевая позиция в контрактах
+// capital*lever/(basePrice*security.Lever)*advice.Position, заявка на разницу с текущей позицией.
+// Заявка исполняется на следующем баре по цене, округленной до шага цены, доход считается в шагах цены
+// по PriceStepCost. Каждый день начинается с капитала capital,
+// базовая цена - цена первого сигнала дня (как при ежедневном перезапуске трейдера).
+func SingleContractExecution(
+	candles iter.Seq2[domain.Candle, error],
+	advisor domain.Advisor,
+	security domain.SecurityInfo,
+	lever float64,
+	settings ExecutionSettings,
+	cost CostModel,
+	skipPnl func(time.Time, time.Time) bool,
+) (ExecutionResult, error) {
+	if security.Lever == 0 {
+		return ExecutionResult{}, fmt.Errorf("execution: no lever for %v", security.Name)
+	}
+	if !(security.PriceStep > 0 && security.PriceStepCost > 0) {
+		return ExecutionResult{}, fmt.Errorf("execution: no price step for %v", security.Name)
+	}
+	var amount = settings.Capital * lever
+
+	var result ExecutionResult
+	var position = 0
+	var pnl = 0.0
+	var basePrice = 0.0
+	var order *pendingOrder
+	var volume = 0.0
+	var lastCandle domain.Candle
+	var lastAdvice domain.Advice
+
+	var newDay = func() contractDay {
+		return contractDay{
+			DateSum:  DateSum{Date: dateTimeToDate(lastCandle.DateTime), Sum: 1 + pnl/settings.Capital},
+			Position: float64(position) * priceStepsCost(lastCandle.ClosePrice, security) / settings.Capital,
+			Price:    lastCandle.ClosePrice,
+			Volume:   volume,
+		}
+	}
+
+	for candle, err := range candles {
+		if err != nil {
+			return ExecutionResult{}, err
+		}
+		if !lastCandle.DateTime.IsZero() {
+			if isNewFortsDateStarted(lastCandle.DateTime, candle.DateTime) {
+				result.days = append(result.days, newDay())
+				pnl = 0
+				volume = 0
+				basePrice = 0
+			}
+			if !skipPnl(lastCandle.DateTime, candle.DateTime) {
+				pnl += float64(position) * priceStepsCost(candle.OpenPrice-lastCandle.ClosePrice, security)
+			}
+		}
+
+		// заявка, выставленная по сигналу прошлого бара
+		if order != nil {
+			var fillPrice, filled = fillOrder(*order, candle, settings.Fill)
+			if filled {
+				fillPrice = roundPrice(fillPrice, security.PriceStep)
+				fee, err := cost(candle.SecurityCode, candle.DateTime, fillPrice)
+				if err != nil {
+					return ExecutionResult{}, err
+				}
+				position += order.volume
+				pnl -= float64(order.volume) * priceStepsCost(fillPrice-candle.OpenPrice, security)
+				pnl -= math.Abs(float64(order.volume)) * priceStepsCost(fillPrice, security) * fee
+				result.Fills++
+				result.fillSlippages += float64(sign(float64(order.volume))) * (fillPrice - order.signalPrice) / order.signalPrice
+			} else {
+				result.MissedFills++
+			}
+			order = nil
+		}
+		pnl += float64(position) * priceStepsCost(candle.ClosePrice-candle.OpenPrice, security)
+		volume += candle.Volume
+		lastCandle = candle
+
+		var advice = advisor(candle)
+		if advice.DateTime.IsZero() {
+			continue
+		}
+		if basePrice == 0 {
+			basePrice = advice.Price
+		}
+		var target = amount / (basePrice * security.Lever) * advice.Position
+		if advice.Position != lastAdvice.Position || lastAdvice.DateTime.IsZero() {
+			result.signals++
+			result.roundings += math.Abs(target - float64(int(target)))
+			if target != 0 && int(target) == 0 {
+				result.ZeroPositions++
+			}
+		}
+		lastAdvice = advice
+		var volume = int(target - float64(position))
+		if volume != 0 {
+			result.Orders++
+			order = &pendingOrder{
+				volume:      volume,
+				signalPrice: advice.Price,
+				limit:       limitPrice(advice.Price, volume, settings.LimitSlippage, security.PriceStep),
+			}
+		}
+	}
+
+	if !lastCandle.DateTime.IsZero() {
+		result.days = append(result.days, newDay())
+	}
+	result.Hprs = contractDaysHprs(result.days)
+	return result, nil
+}
+
+// Цена исполнения заявки на баре. Лимитная заявка исполняется по LimitFillPrice.
+func fillOrder(order pendingOrder, candle domain.Candle, fill string) (float64, bool) {
+	if fill != FillLimit {
+		return candle.OpenPrice, true
+	}
+	return LimitFillPrice(order.volume, order.limit, candle)
+}
+
+// Цена исполнения лимитной заявки на баре (общее правило тестера и воспроизведения торговли).
+// Заявка исполняется, только если бар торговался строго за лимитной ценой, по лучшей из цены открытия и лимитной цены.
+func LimitFillPrice(volume int, price float64, candle domain.Candle) (float64, bool) {
+	if volume > 0 {
+		if candle.LowPrice < price {
+			return math.Min(candle.OpenPrice, price), true
+		}
+		return 0, false
+	}
+	if candle.HighPrice > price {
+		return math.Max(candle.OpenPrice, price), true
+	}
+	return 0, false
+}
+
+func MultiContractExecution(
+	candleStorage domain.ICandleStorage,
+	securityInformator domain.ISecurityInformator,
+	newAdvisor func() domain.Advisor,
+	secCodes []string,
+	lever float64,
+	settings ExecutionSettings,
+	cost CostModel,
+	roll RollSettings,
+	strict bool,
+	skipPnl func(time.Time, time.Time) bool,
+	concurrency int,
+) (ExecutionResult, error) {
+	switch settings.Fill {
+	case FillNextOpen, FillLimit:
+	default:
+		return ExecutionResult{}, fmt.Errorf("bad fill %v", settings.Fill)
+	}
+	if settings.Capital <= 0 {
+		return ExecutionResult{}, fmt.Errorf("bad capital %v", settings.Capital)
+	}
+	var securities = make([]domain.SecurityInfo, len(secCodes))
+	for i, securityCode := range secCodes {
+		security, err := securityInformator.GetSecurityInfo(securityCode)
+		if err != nil {
+			return ExecutionResult{}, err
+		}
+		securities[i] = security
+	}
+
+	var results = make([]ExecutionResult, len(secCodes))
+	var errs = make([]error, len(secCodes))
+	forEachContract(secCodes, concurrency, func(i int, securityCode string) {
+		results[i], errs[i] = SingleContractExecution(
+			candleStorage.Candles(securityCode), newAdvisor(), securities[i], lever, settings, cost, skipPnl)
+	})
+
+	// контракты склеиваются по тому же правилу и с теми же ошибками, что и идеальные доходности (см. multiContract)
+	var daysByContracts = make([][]contractDay, len(secCodes))
+	for i := range results {
+		daysByContracts[i] = results[i].days
+	}
+	var coverage = contractCoverage(secCodes, daysByContracts, errs)
+	if len(secCodes) == 1 && errs[0] != nil {
+		return ExecutionResult{}, errs[0]
+	}
+	if strict {
+		var err = contractsError(coverage)
+		if err != nil {
+			return ExecutionResult{}, err
+		}
+	}
+	var result ExecutionResult
+	for i := range results {
+		if errs[i] != nil {
+			log.Println(errs[i])
+			continue
+		}
+		result.add(results[i])
+	}
+	contracts, err := rollContracts(secCodes, daysByContracts, nil, roll, cost)
+	if err != nil {
+		return ExecutionResult{}, err
+	}
+	result.Hprs = contracts.Hprs
+	result.Coverage = coverage
+	result.Missing = missingSpans(result.Hprs)
+	result.finish()
+	return result, nil
+}
+
+// Сравнение с идеальным исполнением (дробная позиция по цене сигнала)
+func executionTable(ideal []DateSum, result ExecutionResult) reportTable {
+	var monthHpr = func(hprs []DateSum) string {
+		if len(hprs) == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%.1f%%", hprPercent(math.Pow(totalHpr(hprs), 22.0/float64(len(hprs)))))
+	}
+	var drawdown = func(hprs []DateSum) string {
+		if len(hprs) == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%.1f%%", hprPercent(computeDrawdownInfo(hprs).MaxDrawdown))
+	}
+	var table = reportTable{
+		Title:  "Исполнение",
+		Header: []string{"", "Идеальное", "Симуляция"},
+		Rows: [][]string{
+			{"Ежемесячная доходность", monthHpr(ideal), monthHpr(result.Hprs)},
+			{"Максимальная просадка", drawdown(ideal), drawdown(result.Hprs)},
+			{"Заявок", "", fmt.Sprint(result.Orders)},
+			{"Исполнено", "", fmt.Sprint(result.Fills)},
+			{"Не исполнено", "", fmt.Sprint(result.MissedFills)},
+			{"Сигналов с нулевой позицией", "", fmt.Sprint(result.ZeroPositions)},
+			{"Среднее округление позиции", "", fmt.Sprintf("%.2f контр.", result.AvgRounding)},
+			{"Средний сдвиг цены исполнения", "", fmt.Sprintf("%.3f%%", result.AvgFillSlippage*100)},
+		},
+	}
+	// пропущенные контракты и периоды без данных в нестрогом режиме
+	for _, item := range result.Coverage {
+		if item.Error != "" {
+			table.Rows = append(table.Rows, []string{"Пропущен контракт", "", item.SecurityCode})
+		}
+	}
+	for _, span := range result.Missing {
+		table.Rows = append(table.Rows, []string{"Нет данных", "",
+			span.From.Format("02.01.2006") + " - " + span.To.Format("02.01.2006")})
+	}
+	return table
+}
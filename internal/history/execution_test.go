@@ -0,0 +1,161 @@
+package history
+
+import (
+	"advisordev/internal/domain"
+	"advisordev/internal/moex"
+	"errors"
+	"iter"
+	"math"
+	"testing"
+)
+
+func TestSingleContractExecution(t *testing.T) {
+	var security = domain.SecurityInfo{Name: "Si-3.24", PriceStep: 1, PriceStepCost: 1, Lever: 1}
+	result, err := SingleContractExecution(
+		testCandles(100, 100, 110, 110),
+		testPositionsAdvisor(1, 1, 1, 0),
+		security,
+		1,
+		ExecutionSettings{Capital: 250, Fill: FillNextOpen},
+		ConstantCost(0),
+		moex.FortsCalendar.IsAfterHolidays)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2 контракта вместо 2.5, последняя заявка не исполнена до конца данных
+	if len(result.Hprs) != 1 || math.Abs(result.Hprs[0].Sum-1.08) > 1e-9 ||
+		result.Orders != 2 || result.Fills != 1 {
+		t.Error(result)
+	}
+}
+
+type testSecurities map[string]domain.SecurityInfo
+
+func (s testSecurities) GetSecurityInfo(securityName string) (domain.SecurityInfo, error) {
+	return s[securityName], nil
+}
+
+func TestMultiContractExecutionRoll(t *testing.T) {
+	var secCodes = []string{"Si-3.24", "Si-6.24"}
+	var storage = testCandleStorage{
+		"Si-3.24": {100, 100, 110, 110},
+		"Si-6.24": {100, 100, 110, 110},
+	}
+	var securities = testSecurities{
+		"Si-3.24": {Name: "Si-3.24", PriceStep: 1, PriceStepCost: 1, Lever: 1},
+		"Si-6.24": {Name: "Si-6.24", PriceStep: 1, PriceStepCost: 1, Lever: 1},
+	}
+	var newAdvisor = func() domain.Advisor { return testPositionsAdvisor(1, 1, 1, 1) }
+	result, err := MultiContractExecution(storage, securities, newAdvisor, secCodes, 1,
+		ExecutionSettings{Capital: 250, Fill: FillNextOpen}, ConstantCost(0.001), RollSettings{}, true,
+		moex.FortsCalendar.IsAfterHolidays, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2 контракта: доход 20 минус комиссия 0.2. У старого контракта нет дней до перехода,
+	// поэтому издержки перехода - только открытие 2*110/250 капитала в новом.
+	var want = 1 + 19.8/250 - 0.001*0.88
+	if len(result.Hprs) != 1 || math.Abs(result.Hprs[0].Sum-want) > 1e-9 || result.Fills != 2 {
+		t.Error(result, want)
+	}
+}
+
+type testErrorCandleStorage map[string]error
+
+func (s testErrorCandleStorage) Candles(securityCode string) iter.Seq2[domain.Candle, error] {
+	if err := s[securityCode]; err != nil {
+		return func(yield func(domain.Candle, error) bool) {
+			yield(domain.Candle{}, err)
+		}
+	}
+	return testCandles(100, 100, 110, 110)
+}
+
+func TestMultiContractExecutionStrict(t *testing.T) {
+	var secCodes = []string{"Si-3.24", "Si-6.24"}
+	var storage = testErrorCandleStorage{"Si-3.24": errors.New("bad file")}
+	var securities = testSecurities{
+		"Si-3.24": {Name: "Si-3.24", PriceStep: 1, PriceStepCost: 1, Lever: 1},
+		"Si-6.24": {Name: "Si-6.24", PriceStep: 1, PriceStepCost: 1, Lever: 1},
+	}
+	var newAdvisor = func() domain.Advisor { return testPositionsAdvisor(1, 1, 1, 1) }
+	for _, strict := range []bool{true, false} {
+		result, err := MultiContractExecution(storage, securities, newAdvisor, secCodes, 1,
+			ExecutionSettings{Capital: 250, Fill: FillNextOpen}, ConstantCost(0), RollSettings{}, strict,
+			moex.FortsCalendar.IsAfterHolidays, 1)
+		if strict {
+			if err == nil {
+				t.Error("strict: no error")
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Coverage) != 2 || result.Coverage[0].Error == "" || len(result.Hprs) != 1 {
+			t.Error(result)
+		}
+	}
+}
+
+func TestSingleContractExecutionPriceSteps(t *testing.T) {
+	// шаг цены 5 стоит 3, плечо 0.6
+	var security = domain.SecurityInfo{Name: "RTS-3.24", PriceStep: 5, PriceStepCost: 3, Lever: 0.6}
+	result, err := SingleContractExecution(
+		testCandles(100, 100, 110, 110),
+		testPositionsAdvisor(1, 1, 1, 1),
+		security,
+		1,
+		ExecutionSettings{Capital: 250, Fill: FillNextOpen},
+		ConstantCost(0),
+		moex.FortsCalendar.IsAfterHolidays)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 4 контракта (250/(100*0.6) = 4.17), 10 пунктов = 2 шага по 3
+	if len(result.Hprs) != 1 || math.Abs(result.Hprs[0].Sum-(1+4*2*3/250.0)) > 1e-9 {
+		t.Error(result)
+	}
+}
+
+func TestLimitPrice(t *testing.T) {
+	var tests = []struct {
+		price     float64
+		volume    int
+		priceStep float64
+		expected  float64
+	}{
+		{100, 1, 0.5, 100.5},
+		{100, -1, 0.5, 99.5},
+		{100, 1, 1, 100},
+	}
+	for _, test := range tests {
+		var price = limitPrice(test.price, test.volume, 0.003, test.priceStep)
+		if price != test.expected {
+			t.Error(test, price)
+		}
+	}
+}
+
+func TestFillOrder(t *testing.T) {
+	var candle = domain.Candle{OpenPrice: 100, HighPrice: 102, LowPrice: 98, ClosePrice: 101}
+	var tests = []struct {
+		order    pendingOrder
+		fill     string
+		price    float64
+		expected bool
+	}{
+		{order: pendingOrder{volume: 1, limit: 99}, fill: FillNextOpen, price: 100, expected: true},
+		{order: pendingOrder{volume: 1, limit: 99}, fill: FillLimit, price: 99, expected: true},
+		{order: pendingOrder{volume: 1, limit: 101}, fill: FillLimit, price: 100, expected: true},
+		{order: pendingOrder{volume: 1, limit: 98}, fill: FillLimit, expected: false},
+		{order: pendingOrder{volume: -1, limit: 101}, fill: FillLimit, price: 101, expected: true},
+		{order: pendingOrder{volume: -1, limit: 102}, fill: FillLimit, expected: false},
+	}
+	for _, test := range tests {
+		var price, filled = fillOrder(test.order, candle, test.fill)
+		if filled != test.expected || filled && price != test.price {
+			t.Error(test, price, filled)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package history
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Выгрузка в файл. Формат определяется по расширению: .json - value, иначе csv с заголовком header и строками rows.
+func writeJSONOrCSV(path string, value any, header []string, rows [][]string) error {
+	var file, err = os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var encoder = json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(value)
+		if err != nil {
+			return err
+		}
+		return file.Close()
+	}
+
+	var writer = csv.NewWriter(file)
+	writer.Write(header)
+	writer.WriteAll(rows)
+	if err = writer.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -6,85 +6,223 @@ import (
 	"advisordev/internal/moex"
 	"fmt"
 	"log"
+	"os"
 	"runtime"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
-func AdvisorStatus(
-	candleStorage domain.ICandleStorage,
-	advisorName string,
-	securityName string,
-) error {
-	var advisor = advisors.TestAdvisor(advisorName)
-	var advices []domain.Advice
-	for candle, err := range candleStorage.Candles(securityName) {
-		if err != nil {
-			return err
-		}
-		var advice = advisor(candle)
-		if advice.DateTime.IsZero() {
-			continue
-		}
-		if len(advices) == 0 ||
-			advices[len(advices)-1].Position != advice.Position {
-			advices = append(advices, advice)
-		}
-	}
-	const N = 10
-	for _, item := range advices[max(0, len(advices)-N):] {
-		fmt.Println(item)
-	}
-	return nil
+type ReportSettings struct {
+	AdvisorName   string
+	AdvisorParams advisors.Params
+	SecurityName  string
+	// Если 0, то подбирается по правилу Sizing
+	Lever         float64
+	Sizing        SizingSettings
+	Cost          CostModel
+	TimeRange     moex.TimeRange
+	MultiContract bool
+	// Правило перехода на следующий контракт
+	Roll RollSettings
+	// Ошибка любого контракта прерывает тест. Иначе контракт пропускается и попадает в отчет.
+	Strict bool
+	// Если MonteCarlo.Simulations == 0, то доверительные интервалы не считаются
+	MonteCarlo MonteCarloSettings
+	// Статистика по сделкам
+	Trades bool
+	// Файл для выгрузки списка сделок (csv или json)
+	TradesPath string
+	// Инструмент для сравнения (купил и держи). Если пусто, то сравнения нет.
+	Benchmark              string
+	BenchmarkMultiContract bool
+	// Симуляция исполнения целым числом контрактов, если Execution.Capital != 0
+	Execution ExecutionSettings
+	// Справочник инструментов для симуляции исполнения. Если nil, то встроенный в программу.
+	Securities domain.ISecurityInformator
+	// Перебор издержек: доходность, просадка и плечо при разных издержках
+	Sensitivity SensitivitySettings
+	// Уровни CVaR, например 0.01 и 0.05
+	CVaRLevels []float64
+	// ReportFormatText, ReportFormatJson, ReportFormatCsv или ReportFormatHtml
+	Format string
+	// Файл отчета. Если пусто, то отчет выводится в консоль.
+	OutputPath string
+	// Каталог хранилища прогонов. Если пусто, то прогон не сохраняется.
+	RunStore string
+	// Аргументы командной строки для хранилища прогонов
+	Args []string
 }
 
 func AdvisorReport(
 	candleStorage domain.ICandleStorage,
-	advisorName string,
-	securityName string,
-	lever float64,
-	slippage float64,
-	startYear int,
-	startQuarter int,
-	finishYear int,
-	finishQuarter int,
-	multiContract bool,
+	settings ReportSettings,
 ) error {
 	var start = time.Now()
 	defer func() {
-		fmt.Println("Elapsed:", time.Since(start))
+		fmt.Fprintln(os.Stderr, "Elapsed:", time.Since(start))
 	}()
 
-	var secCodes []string
-	if multiContract {
-		var tr = moex.TimeRange{
-			StartYear:     startYear,
-			StartQuarter:  startQuarter,
-			FinishYear:    finishYear,
-			FinishQuarter: finishQuarter,
-		}
-		secCodes = moex.QuarterSecurityCodes(securityName, tr)
-	} else {
-		secCodes = []string{securityName}
+	writeReport, err := NewReportWriter(settings.Format)
+	if err != nil {
+		return err
 	}
 
-	var hprs, err = MultiContractHprs(
-		candleStorage, advisorName, secCodes, slippage, isAfterLongHolidays, runtime.NumCPU())
+	var secCodes = securityCodes(settings.SecurityName, settings.TimeRange, settings.MultiContract)
+
+	// отпечаток данных для хранилища прогонов считается при чтении баров тестом
+	var fingerprints *fingerprintStorage
+	if settings.RunStore != "" {
+		fingerprints = newFingerprintStorage(candleStorage)
+		candleStorage = fingerprints
+	}
+
+	newAdvisor, err := advisors.TestAdvisor(settings.AdvisorName, settings.AdvisorParams)
+	if err != nil {
+		return err
+	}
+
+	err = settings.Roll.Validate()
 	if err != nil {
 		return err
 	}
+	err = settings.Sizing.Validate()
+	if err != nil {
+		return err
+	}
+	contracts, err := multiContract(
+		candleStorage, newAdvisor, secCodes, settings.Cost, settings.Roll, settings.Strict,
+		moex.FortsCalendar.IsAfterHolidays, runtime.NumCPU())
+	if err != nil {
+		return err
+	}
+	var hprs = contracts.Hprs
+	if len(hprs) == 0 {
+		return fmt.Errorf("no data %v", settings.SecurityName)
+	}
+	var lever = settings.Lever
+	var sizing *SizingResult
 	if lever == 0 {
-		lever = optimalLever(hprs, limitStDev(0.045))
+		result, err := selectLever(hprs, settings.Sizing)
+		if err != nil {
+			return err
+		}
+		lever = result.Lever
+		sizing = &result
 	}
 	hprs = hprsWithLever(hprs, lever)
 
-	fmt.Println("Отчет", advisorName, securityName)
-	fmt.Printf("Плечо: %.1f\n", lever)
-	ReportDailyResults(hprs)
+	var report = Report{
+		AdvisorName:   settings.AdvisorName,
+		AdvisorParams: settings.AdvisorParams,
+		SecurityName:  settings.SecurityName,
+		Lever:         lever,
+		Sizing:        sizing,
+		Statistics:    computeHprStatistcs(hprs, settings.CVaRLevels),
+		Rolling:       computeRollingWindows(hprs),
+		Breakdowns:    []Breakdown{weekdayBreakdown(hprs), monthOfYearBreakdown(hprs)},
+		Rolls:         contracts.Rolls,
+		Coverage:      contracts.Coverage,
+		Missing:       contracts.Missing,
+	}
+
+	if settings.Benchmark != "" {
+		var benchmarkCodes = securityCodes(settings.Benchmark, settings.TimeRange, settings.BenchmarkMultiContract)
+		benchmark, err := benchmarkHprs(candleStorage, benchmarkCodes, settings.Roll, settings.Strict)
+		if err != nil {
+			return err
+		}
+		stat, err := computeBenchmarkStatistics(settings.Benchmark, hprs, benchmark)
+		if err != nil {
+			return err
+		}
+		report.Benchmark = &stat
+	}
+
+	if settings.Execution.Capital != 0 {
+		var securities = settings.Securities
+		if securities == nil {
+			securities = moex.NewFortsSecurityInformator()
+		}
+		result, err := MultiContractExecution(
+			candleStorage, securities, newAdvisor, secCodes, lever,
+			settings.Execution, settings.Cost, settings.Roll, settings.Strict, moex.FortsCalendar.IsAfterHolidays, runtime.NumCPU())
+		if err != nil {
+			return err
+		}
+		report.Execution = &result
+	}
+
+	var trades = tradesWithLever(contracts.Trades, lever)
+	if settings.Trades || settings.TradesPath != "" {
+		var stat = computeTradeStatistics(trades)
+		report.Trades = &stat
+		report.Breakdowns = append(report.Breakdowns, entryHourBreakdown(trades))
+		if settings.TradesPath != "" {
+			err = WriteTrades(settings.TradesPath, trades)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(settings.Sensitivity.Slippages) != 0 || len(settings.Sensitivity.CostMultipliers) != 0 {
+		result, err := costSensitivity(candleStorage, newAdvisor, secCodes, settings)
+		if err != nil {
+			return err
+		}
+		report.Sensitivity = &result
+	}
+
+	if settings.MonteCarlo.Simulations != 0 {
+		result, err := MonteCarlo(hprs, trades, settings.MonteCarlo)
+		if err != nil {
+			return err
+		}
+		report.MonteCarlo = &result
+	}
+
+	err = writeReportOutput(writeReport, settings.OutputPath, report)
+	if err != nil {
+		return err
+	}
+
+	if settings.RunStore != "" {
+		run, err := SaveRun(settings.RunStore, fingerprints.Fingerprint(secCodes), secCodes, report, settings.Args)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "Run:", run.ID)
+	}
 	return nil
 }
 
+func writeReportOutput(writeReport ReportWriter, path string, report Report) error {
+	if path == "" {
+		return writeReport(os.Stdout, report)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	err = writeReport(file, report)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func securityCodes(securityName string, tr moex.TimeRange, multiContract bool) []string {
+	if multiContract {
+		return moex.QuarterSecurityCodes(securityName, tr)
+	}
+	return []string{securityName}
+}
+
+// Ограничение на дневное стандартное отклонение при подборе плеча
+const defaultStDevLimit = 0.045
+
 func limitStDev(stDev float64) func([]DateSum) bool {
 	return func(source []DateSum) bool {
 		return stDevHprs(source) <= stDev
@@ -133,24 +271,69 @@ func hprsWithLever(source []DateSum, lever float64) []DateSum {
 
 func MultiContractHprs(
 	candleStorage domain.ICandleStorage,
-	advisorName string,
+	newAdvisor func() domain.Advisor,
 	secCodes []string,
-	slippage float64,
+	cost CostModel,
+	roll RollSettings,
+	strict bool,
 	skipPnl func(time.Time, time.Time) bool,
 	concurrency int,
 ) ([]DateSum, error) {
-	if len(secCodes) == 1 {
-		return SingleContractHprs(
-			candleStorage.Candles(secCodes[0]),
-			advisors.TestAdvisor(advisorName),
-			slippage,
+	var result, err = multiContract(candleStorage, newAdvisor, secCodes, cost, roll, strict, skipPnl, concurrency)
+	return result.Hprs, err
+}
+
+func multiContract(
+	candleStorage domain.ICandleStorage,
+	newAdvisor func() domain.Advisor,
+	secCodes []string,
+	cost CostModel,
+	roll RollSettings,
+	strict bool,
+	skipPnl func(time.Time, time.Time) bool,
+	concurrency int,
+) (MultiContractResult, error) {
+	var daysByContracts = make([][]contractDay, len(secCodes))
+	var tradesByContracts = make([][]Trade, len(secCodes))
+	var errs = make([]error, len(secCodes))
+	forEachContract(secCodes, concurrency, func(i int, securityCode string) {
+		daysByContracts[i], tradesByContracts[i], errs[i] = singleContractDays(
+			candleStorage.Candles(securityCode),
+			newAdvisor(),
+			cost,
 			skipPnl)
+	})
+	var coverage = contractCoverage(secCodes, daysByContracts, errs)
+	if len(secCodes) == 1 && errs[0] != nil {
+		return MultiContractResult{}, errs[0]
+	}
+	// Нестрогий режим: контракты с ошибками пропускаются, пропуски видны в Coverage и Missing
+	if strict {
+		var err = contractsError(coverage)
+		if err != nil {
+			return MultiContractResult{}, err
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	result, err := rollContracts(secCodes, daysByContracts, tradesByContracts, roll, cost)
+	if err != nil {
+		return MultiContractResult{}, err
 	}
+	result.Coverage = coverage
+	result.Missing = missingSpans(result.Hprs)
+	return result, nil
+}
 
+// Вызывает f для каждого контракта в concurrency потоков
+func forEachContract(secCodes []string, concurrency int, f func(i int, securityCode string)) {
 	var index int32 = -1
 	var wg = &sync.WaitGroup{}
-	var hprsByContracts = make([][]DateSum, len(secCodes))
-	for threadIndex := 0; threadIndex < concurrency; threadIndex++ {
+	for threadIndex := 0; threadIndex < max(1, concurrency); threadIndex++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
@@ -159,47 +342,9 @@ func MultiContractHprs(
 				if i >= len(secCodes) {
 					break
 				}
-				var securityCode = secCodes[i]
-				var hprs, err = SingleContractHprs(
-					candleStorage.Candles(securityCode),
-					advisors.TestAdvisor(advisorName),
-					slippage,
-					skipPnl)
-				if err != nil {
-					log.Println(err)
-					continue
-				}
-				hprsByContracts[i] = hprs
+				f(i, secCodes[i])
 			}
 		}()
 	}
 	wg.Wait()
-
-	return concatHprs(hprsByContracts), nil
-}
-
-func concatHprs(hprsByContracts [][]DateSum) []DateSum {
-	var result []DateSum
-	for _, hprs := range hprsByContracts {
-		if len(hprs) == 0 {
-			continue
-		}
-
-		if len(result) != 0 {
-			// последний день предыдущего контракта может быть не полный
-			result = result[:len(result)-1]
-		}
-
-		var last = time.Time{}
-		if len(result) != 0 {
-			last = result[len(result)-1].Date
-		}
-		for i := 0; i < len(hprs); i++ {
-			if hprs[i].Date.After(last) {
-				result = append(result, hprs[i:]...)
-				break
-			}
-		}
-	}
-	return result
 }
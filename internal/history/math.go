@@ -25,3 +25,22 @@ func stDev(source []float64) float64 {
 	var _, stDev = moments(source)
 	return stDev
 }
+
+// Коэффициент корреляции Пирсона
+func correlation(x, y []float64) float64 {
+	var n = min(len(x), len(y))
+	if n == 0 {
+		return math.NaN()
+	}
+	var meanX, stDevX = moments(x[:n])
+	var meanY, stDevY = moments(y[:n])
+	if stDevX == 0 || stDevY == 0 {
+		return math.NaN()
+	}
+	var cov = 0.0
+	for i := 0; i < n; i++ {
+		cov += (x[i] - meanX) * (y[i] - meanY)
+	}
+	cov /= float64(n)
+	return cov / (stDevX * stDevY)
+}
@@ -0,0 +1,131 @@
+package history
+
+import (
+	"math"
+)
+
+// Торговых дней в году на FORTS, если по данным оценить нельзя
+const defaultTradingDaysPerYear = 250
+
+// Число торговых дней в году по фактическим датам доходностей
+func tradingDaysPerYear(hprs []DateSum) float64 {
+	if len(hprs) < 2 {
+		return defaultTradingDaysPerYear
+	}
+	var years = hprs[len(hprs)-1].Date.Sub(hprs[0].Date).Hours() / 24 / 365.25
+	if years < 0.5 {
+		return defaultTradingDaysPerYear
+	}
+	return float64(len(hprs)-1) / years
+}
+
+// Годовой коэффициент Шарпа по логарифмам дневных доходностей (безрисковая ставка 0)
+func sharpeRatio(hprs []DateSum) float64 {
+	var mean, stDev = moments(logHprs(hprs))
+	if stDev == 0 {
+		return 0
+	}
+	return mean / stDev * math.Sqrt(tradingDaysPerYear(hprs))
+}
+
+// Уровни CVaR по умолчанию: доли худших дней
+var defaultCVaRLevels = []float64{0.01, 0.05}
+
+// Средняя доходность худших дней
+type CVaR struct {
+	Level float64
+	Hpr   float64
+}
+
+// sortedHprs отсортированы по возрастанию доходности. Берем хотя бы один день.
+func computeCVaR(sortedHprs []DateSum, levels []float64) []CVaR {
+	var result = make([]CVaR, len(levels))
+	for i, level := range levels {
+		var n = max(1, int(math.Ceil(level*float64(len(sortedHprs))-1e-9)))
+		result[i] = CVaR{Level: level, Hpr: meanBySum(sortedHprs[:min(n, len(sortedHprs))])}
+	}
+	return result
+}
+
+func logHprs(hprs []DateSum) []float64 {
+	var x = make([]float64, len(hprs))
+	for i := range hprs {
+		x[i] = math.Log(hprs[i].Sum)
+	}
+	return x
+}
+
+// Годовая доходность (CAGR) по торговому календарю FORTS
+func annualHpr(hprs []DateSum) float64 {
+	return math.Pow(totalHpr(hprs), tradingDaysPerYear(hprs)/float64(len(hprs)))
+}
+
+// Годовой коэффициент Сортино: как Шарп, но в знаменателе только отрицательные отклонения от 0
+func sortinoRatio(hprs []DateSum) float64 {
+	var x = logHprs(hprs)
+	var mean, _ = moments(x)
+	var downside = 0.0
+	for _, v := range x {
+		downside += math.Pow(min(v, 0), 2)
+	}
+	downside = math.Sqrt(downside / float64(len(x)))
+	if downside == 0 {
+		return 0
+	}
+	return mean / downside * math.Sqrt(tradingDaysPerYear(hprs))
+}
+
+// Годовая доходность к максимальной просадке
+func calmarRatio(hprs []DateSum, maxDrawdown float64) float64 {
+	if maxDrawdown >= 1 {
+		return 0
+	}
+	return (annualHpr(hprs) - 1) / (1 - maxDrawdown)
+}
+
+// Итоговая прибыль к максимальной просадке
+func recoveryFactor(hprs []DateSum, maxDrawdown float64) float64 {
+	if maxDrawdown >= 1 {
+		return 0
+	}
+	return (totalHpr(hprs) - 1) / (1 - maxDrawdown)
+}
+
+// Индекс Ulcer: среднеквадратичная просадка от максимума эквити по всем дням
+func ulcerIndex(hprs []DateSum) float64 {
+	var sum = 0.0
+	for _, p := range equityCurve(hprs) {
+		sum += math.Pow(1-p.Drawdown, 2)
+	}
+	return math.Sqrt(sum / float64(len(hprs)))
+}
+
+// Асимметрия и эксцесс (kurtosis-3) выборки
+func skewnessKurtosis(x []float64) (skewness, kurtosis float64) {
+	var mean, stDev = moments(x)
+	if len(x) == 0 || stDev == 0 {
+		return 0, 0
+	}
+	var m3, m4 = 0.0, 0.0
+	for _, v := range x {
+		var d = (v - mean) / stDev
+		m3 += d * d * d
+		m4 += d * d * d * d
+	}
+	var n = float64(len(x))
+	return m3 / n, m4/n - 3
+}
+
+// Доля периодов с положительной доходностью
+func profitableShare(hprs []DateSum) float64 {
+	if len(hprs) == 0 {
+		return 0
+	}
+	var count = 0
+	for _, hpr := range hprs {
+		if hpr.Sum > 1 {
+			count++
+		}
+	}
+	return float64(count) / float64(len(hprs))
+}
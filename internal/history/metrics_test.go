@@ -0,0 +1,92 @@
+package history
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSkewnessKurtosis(t *testing.T) {
+	var tests = []struct {
+		x        []float64
+		skewness float64
+		kurtosis float64
+	}{
+		{x: []float64{1, 2, 3}, skewness: 0, kurtosis: -1.5},
+		{x: []float64{0, 0, 0, 1}, skewness: 2 / math.Sqrt(3), kurtosis: -2.0 / 3},
+		{x: []float64{5, 5}, skewness: 0, kurtosis: 0},
+	}
+	for _, test := range tests {
+		var skewness, kurtosis = skewnessKurtosis(test.x)
+		if math.Abs(skewness-test.skewness) > 1e-9 ||
+			math.Abs(kurtosis-test.kurtosis) > 1e-9 {
+			t.Error(test, skewness, kurtosis)
+		}
+	}
+}
+
+func TestRiskMetrics(t *testing.T) {
+	var tests = []struct {
+		name     string
+		metric   func([]DateSum) float64
+		hprs     []DateSum
+		expected float64
+	}{
+		{
+			name:     "sortino",
+			metric:   sortinoRatio,
+			hprs:     testHprs(math.Exp(0.02), math.Exp(-0.01), math.Exp(0.02), math.Exp(-0.01)),
+			expected: 0.005 / math.Sqrt(0.00005) * math.Sqrt(defaultTradingDaysPerYear),
+		},
+		{
+			name:     "sortino without losses",
+			metric:   sortinoRatio,
+			hprs:     testHprs(1.01, 1.02),
+			expected: 0,
+		},
+		{
+			name:     "ulcer",
+			metric:   ulcerIndex,
+			hprs:     testHprs(0.5, 2),
+			expected: math.Sqrt(0.125),
+		},
+		{
+			name: "recovery factor",
+			metric: func(hprs []DateSum) float64 {
+				return recoveryFactor(hprs, computeDrawdownInfo(hprs).MaxDrawdown)
+			},
+			hprs:     testHprs(0.5, 2, 1.1),
+			expected: 0.2,
+		},
+		{
+			name: "calmar",
+			metric: func(hprs []DateSum) float64 {
+				return calmarRatio(hprs, computeDrawdownInfo(hprs).MaxDrawdown)
+			},
+			hprs:     testHprs(0.5, 2, 1.1),
+			expected: (math.Pow(1.1, defaultTradingDaysPerYear/3.0) - 1) / 0.5,
+		},
+		{
+			name:     "profitable days",
+			metric:   profitableShare,
+			hprs:     testHprs(1.01, 1, 0.99, 1.02),
+			expected: 0.5,
+		},
+	}
+	for _, test := range tests {
+		var y = test.metric(test.hprs)
+		if math.Abs(y-test.expected) > 1e-9*math.Max(1, math.Abs(test.expected)) {
+			t.Error(test.name, y, test.expected)
+		}
+	}
+}
+
+func TestComputeCVaR(t *testing.T) {
+	var sorted = testHprs(0.9, 0.95, 1, 1.05, 1.1, 1.1, 1.1, 1.1, 1.1, 1.1)
+	var result = computeCVaR(sorted, []float64{0.01, 0.2, 0.5})
+	var expected = []float64{0.9, 0.925, 1}
+	for i := range expected {
+		if math.Abs(result[i].Hpr-expected[i]) > 1e-9 {
+			t.Error(result[i], expected[i])
+		}
+	}
+}
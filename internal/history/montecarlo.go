@@ -0,0 +1,199 @@
+package history
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+const (
+	// Стационарный блочный бутстрап (Politis, Romano): сохраняет автокорреляцию доходностей
+	ResampleBlock = "block"
+	// Перестановка дневных доходностей без повторов: итоговая доходность та же, меняется только порядок и просадки
+	ResampleDays = "days"
+	// Перестановка доходностей сделок без повторов: порядок сделок случаен, даты выхода остаются исходными
+	ResampleTrades = "trades"
+)
+
+type MonteCarloSettings struct {
+	Method      string
+	Simulations int
+	// Средняя длина блока в днях для ResampleBlock
+	BlockLength float64
+	// Разорение - эквити опустилось до этой доли начального капитала
+	RuinEquity float64
+	Seed       int64
+}
+
+// Доверительный интервал 90% и медиана
+type ConfidenceInterval struct {
+	Low    float64
+	Median float64
+	High   float64
+}
+
+type MonteCarloResult struct {
+	Method          string
+	Simulations     int
+	MonthHpr        ConfidenceInterval
+	MaxDrawdown     ConfidenceInterval
+	LongestDrawdown ConfidenceInterval
+	RuinProbability float64
+}
+
+// trades нужны только для ResampleTrades. Ежемесячная доходность считается по числу дней hprs.
+func MonteCarlo(hprs []DateSum, trades []Trade, settings MonteCarloSettings) (MonteCarloResult, error) {
+	if len(hprs) == 0 {
+		return MonteCarloResult{}, fmt.Errorf("montecarlo: no data")
+	}
+	if settings.Simulations <= 0 {
+		return MonteCarloResult{}, fmt.Errorf("montecarlo: bad simulations %v", settings.Simulations)
+	}
+	var rnd = rand.New(rand.NewSource(settings.Seed))
+	var source = hprs
+	var resample func([]DateSum, []DateSum)
+	switch settings.Method {
+	case ResampleBlock:
+		if settings.BlockLength < 1 {
+			return MonteCarloResult{}, fmt.Errorf("montecarlo: bad block length %v", settings.BlockLength)
+		}
+		resample = func(dst, src []DateSum) {
+			stationaryBootstrap(dst, src, settings.BlockLength, rnd)
+		}
+	case ResampleDays:
+		resample = func(dst, src []DateSum) {
+			shuffleHprs(dst, src, rnd)
+		}
+	case ResampleTrades:
+		if len(trades) == 0 {
+			return MonteCarloResult{}, fmt.Errorf("montecarlo: no trades")
+		}
+		source = tradeHprs(trades)
+		resample = func(dst, src []DateSum) {
+			shuffleHprs(dst, src, rnd)
+		}
+	default:
+		return MonteCarloResult{}, fmt.Errorf("montecarlo: bad method %v", settings.Method)
+	}
+
+	var monthHprs = make([]float64, settings.Simulations)
+	var maxDrawdowns = make([]float64, settings.Simulations)
+	var longestDrawdowns = make([]float64, settings.Simulations)
+	var ruins = 0
+	var path = make([]DateSum, len(source))
+	for i := 0; i < settings.Simulations; i++ {
+		resample(path, source)
+		var drawdown = computeDrawdownInfo(path)
+		monthHprs[i] = math.Pow(totalHpr(path), 22.0/float64(len(hprs)))
+		maxDrawdowns[i] = drawdown.MaxDrawdown
+		longestDrawdowns[i] = float64(drawdown.LongestDrawdown)
+		if isRuined(path, settings.RuinEquity) {
+			ruins++
+		}
+	}
+
+	return MonteCarloResult{
+		Method:          settings.Method,
+		Simulations:     settings.Simulations,
+		MonthHpr:        confidenceInterval(monthHprs),
+		MaxDrawdown:     confidenceInterval(maxDrawdowns),
+		LongestDrawdown: confidenceInterval(longestDrawdowns),
+		RuinProbability: float64(ruins) / float64(settings.Simulations),
+	}, nil
+}
+
+// Доходности берутся блоками случайной (геометрической) длины со средним blockLength.
+// Даты остаются исходными, чтобы продолжительность просадок считалась в тех же календарных днях.
+func stationaryBootstrap(dst, src []DateSum, blockLength float64, rnd *rand.Rand) {
+	var p = 1 / blockLength
+	var j = rnd.Intn(len(src))
+	for i := range dst {
+		if i != 0 {
+			if rnd.Float64() < p {
+				j = rnd.Intn(len(src))
+			} else {
+				j = (j + 1) % len(src)
+			}
+		}
+		dst[i] = DateSum{Date: src[i].Date, Sum: src[j].Sum}
+	}
+}
+
+// Доходность каждой сделки на дату выхода, в порядке выхода
+func tradeHprs(trades []Trade) []DateSum {
+	var sorted = make([]Trade, len(trades))
+	copy(sorted, trades)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ExitTime.Before(sorted[j].ExitTime)
+	})
+	var result = make([]DateSum, len(sorted))
+	for i, trade := range sorted {
+		result[i] = DateSum{Date: dateTimeToDate(trade.ExitTime), Sum: 1 + trade.Pnl}
+	}
+	return result
+}
+
+func shuffleHprs(dst, src []DateSum, rnd *rand.Rand) {
+	for i, j := range rnd.Perm(len(src)) {
+		dst[i] = DateSum{Date: src[i].Date, Sum: src[j].Sum}
+	}
+}
+
+func isRuined(hprs []DateSum, ruinEquity float64) bool {
+	var equity = 1.0
+	for _, hpr := range hprs {
+		equity *= hpr.Sum
+		if equity <= ruinEquity {
+			return true
+		}
+	}
+	return false
+}
+
+func confidenceInterval(source []float64) ConfidenceInterval {
+	var sorted = make([]float64, len(source))
+	copy(sorted, source)
+	sort.Float64s(sorted)
+	return ConfidenceInterval{
+		Low:    percentile(sorted, 0.05),
+		Median: percentile(sorted, 0.5),
+		High:   percentile(sorted, 0.95),
+	}
+}
+
+// Перцентиль отсортированной выборки с линейной интерполяцией
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	var pos = p * float64(len(sorted)-1)
+	var i = int(pos)
+	if i+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	var frac = pos - float64(i)
+	return sorted[i] + frac*(sorted[i+1]-sorted[i])
+}
+
+func monteCarloTable(result MonteCarloResult) reportTable {
+	var interval = func(x ConfidenceInterval, format func(float64) string) []string {
+		return []string{format(x.Low), format(x.Median), format(x.High)}
+	}
+	var percent = func(hpr float64) string {
+		return fmt.Sprintf("%.1f%%", hprPercent(hpr))
+	}
+	var days = func(x float64) string {
+		return fmt.Sprintf("%.0f дн.", x)
+	}
+	return reportTable{
+		Title:  fmt.Sprintf("Монте-Карло %v симуляций %v", result.Method, result.Simulations),
+		Header: []string{"", "5%", "медиана", "95%"},
+		Rows: [][]string{
+			append([]string{"Ежемесячная доходность"}, interval(result.MonthHpr, percent)...),
+			append([]string{"Максимальная просадка"}, interval(result.MaxDrawdown, percent)...),
+			append([]string{"Продолжительная просадка"}, interval(result.LongestDrawdown, days)...),
+			{"Вероятность разорения", "", fmt.Sprintf("%.1f%%", result.RuinProbability*100), ""},
+		},
+	}
+}
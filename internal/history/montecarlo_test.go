@@ -0,0 +1,92 @@
+package history
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func testHprs(sums ...float64) []DateSum {
+	var result = make([]DateSum, len(sums))
+	var date = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for i, sum := range sums {
+		result[i] = DateSum{Date: date.AddDate(0, 0, i), Sum: sum}
+	}
+	return result
+}
+
+func TestShuffleHprsKeepsTotalHpr(t *testing.T) {
+	var hprs = testHprs(1.01, 0.98, 1.03, 0.99, 1.02, 0.97)
+	var path = make([]DateSum, len(hprs))
+	var rnd = rand.New(rand.NewSource(1))
+	for i := 0; i < 10; i++ {
+		shuffleHprs(path, hprs, rnd)
+		if math.Abs(totalHpr(path)-totalHpr(hprs)) > 1e-12 {
+			t.Error(path)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var sorted = []float64{1, 2, 3, 4, 5}
+	var tests = []struct {
+		p        float64
+		expected float64
+	}{
+		{p: 0, expected: 1},
+		{p: 0.5, expected: 3},
+		{p: 0.625, expected: 3.5},
+		{p: 1, expected: 5},
+	}
+	for _, test := range tests {
+		var y = percentile(sorted, test.p)
+		if y != test.expected {
+			t.Error(test, y)
+		}
+	}
+}
+
+func TestMonteCarloRuin(t *testing.T) {
+	var hprs = testHprs(0.5, 0.5, 0.5, 0.5)
+	result, err := MonteCarlo(hprs, nil, MonteCarloSettings{
+		Method:      ResampleBlock,
+		Simulations: 100,
+		BlockLength: 2,
+		RuinEquity:  0.5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RuinProbability != 1 {
+		t.Error(result)
+	}
+}
+
+func TestMonteCarloTrades(t *testing.T) {
+	var hprs = testHprs(1.01, 1.01, 1.01, 1.01, 1.01, 1.01, 1.01, 1.01, 1.01, 1.01, 1.01)
+	var start = time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	var trades []Trade
+	for i, pnl := range []float64{0.1, -0.05, 0.2, -0.1} {
+		trades = append(trades, Trade{ExitTime: start.AddDate(0, 0, 2*i), Pnl: pnl})
+	}
+	result, err := MonteCarlo(hprs, trades, MonteCarloSettings{
+		Method:      ResampleTrades,
+		Simulations: 100,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// перестановка сделок не меняет итоговую доходность
+	var want = math.Pow(1.1*0.95*1.2*0.9, 22.0/11)
+	if math.Abs(result.MonthHpr.Low-want) > 1e-9 || math.Abs(result.MonthHpr.High-want) > 1e-9 {
+		t.Error(result.MonthHpr, want)
+	}
+	// худший порядок - убытки подряд, лучший - только наибольший убыток
+	if math.Abs(result.MaxDrawdown.Low-0.9*0.95) > 1e-9 || math.Abs(result.MaxDrawdown.High-0.9) > 1e-9 {
+		t.Error(result.MaxDrawdown)
+	}
+	if _, err = MonteCarlo(hprs, nil, MonteCarloSettings{Method: ResampleTrades, Simulations: 1}); err == nil {
+		t.Error("no trades")
+	}
+}
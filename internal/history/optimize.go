@@ -0,0 +1,342 @@
+package history
+
+import (
+	advisors "advisordev/internal/advisors_sample"
+	"advisordev/internal/domain"
+	"advisordev/internal/moex"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	ObjectiveHpr      = "hpr"
+	ObjectiveSharpe   = "sharpe"
+	ObjectiveDrawdown = "drawdown"
+)
+
+// Диапазон значений параметра: From, From+Step, ..., To
+type ParamRange struct {
+	Name string
+	From float64
+	To   float64
+	Step float64
+}
+
+func (r ParamRange) size() int {
+	if r.Step <= 0 || r.To <= r.From {
+		return 1
+	}
+	return int(math.Floor((r.To-r.From)/r.Step+1e-9)) + 1
+}
+
+func (r ParamRange) value(i int) float64 {
+	// округление убирает накопленную погрешность шага (0.1+0.2)
+	var v = r.From + float64(i)*r.Step
+	return math.Round(v*1e9) / 1e9
+}
+
+// Разбирает строку вида "fast=10:100:10,slow=200,stdvolatility=0.004:0.008:0.001".
+// Одно значение задает фиксированный параметр.
+func ParseParamRanges(s string) ([]ParamRange, error) {
+	var result []ParamRange
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, values, found := strings.Cut(item, "=")
+		if !found {
+			return nil, fmt.Errorf("bad param range %q", item)
+		}
+		var parts = strings.Split(values, ":")
+		var numbers = make([]float64, len(parts))
+		for i, part := range parts {
+			v, err := advisors.ParseParamValue(part)
+			if err != nil {
+				return nil, err
+			}
+			numbers[i] = v
+		}
+		var r = ParamRange{Name: strings.TrimSpace(name)}
+		switch len(numbers) {
+		case 1:
+			r.From, r.To = numbers[0], numbers[0]
+		case 3:
+			r.From, r.To, r.Step = numbers[0], numbers[1], numbers[2]
+			if r.Step <= 0 || r.To < r.From {
+				return nil, fmt.Errorf("bad param range %q", item)
+			}
+		default:
+			return nil, fmt.Errorf("bad param range %q: expected value or from:to:step", item)
+		}
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+// Все комбинации значений параметров
+func gridParams(ranges []ParamRange) []advisors.Params {
+	var result = []advisors.Params{{}}
+	for _, r := range ranges {
+		var next []advisors.Params
+		for _, params := range result {
+			for i := 0; i < r.size(); i++ {
+				var p = params.Clone()
+				p[r.Name] = r.value(i)
+				next = append(next, p)
+			}
+		}
+		result = next
+	}
+	return result
+}
+
+// Случайная выборка без повторов из сетки значений. Сетку целиком не строим, она может быть огромной.
+func randomParams(ranges []ParamRange, samples int, rnd *rand.Rand) []advisors.Params {
+	var gridSize = 1.0
+	for _, r := range ranges {
+		gridSize *= float64(r.size())
+	}
+	if float64(samples) >= gridSize {
+		return gridParams(ranges)
+	}
+	var result []advisors.Params
+	var seen = make(map[string]bool)
+	for len(result) < samples {
+		var p = make(advisors.Params, len(ranges))
+		for _, r := range ranges {
+			p[r.Name] = r.value(rnd.Intn(r.size()))
+		}
+		var key = p.String()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, p)
+	}
+	return result
+}
+
+type OptimizeSettings struct {
+	AdvisorName   string
+	ParamRanges   []ParamRange
+	SecurityName  string
+	TimeRange     moex.TimeRange
+	MultiContract bool
+	Cost          CostModel
+	Roll          RollSettings
+	Strict        bool
+	// Если 0, то плечо подбирается для каждого прогона, как в отчете
+	Lever float64
+	// ObjectiveHpr, ObjectiveSharpe или ObjectiveDrawdown
+	Objective string
+	// Ограничение просадки для ObjectiveDrawdown, например 0.3
+	MaxDrawdown float64
+	// Если 0, то перебираем всю сетку
+	Samples     int
+	Seed        int64
+	Concurrency int
+}
+
+// Результат одного прогона оптимизации
+type OptimizationRun struct {
+	Params      advisors.Params
+	Lever       float64
+	TotalHpr    float64
+	MonthHpr    float64
+	Sharpe      float64
+	MaxDrawdown float64
+	Days        int
+	Objective   float64
+	// Прогон удовлетворяет ограничениям целевой функции
+	Feasible bool
+	Error    string `json:",omitempty"`
+}
+
+func Optimize(
+	candleStorage domain.ICandleStorage,
+	settings OptimizeSettings,
+) ([]OptimizationRun, error) {
+	var secCodes = securityCodes(settings.SecurityName, settings.TimeRange, settings.MultiContract)
+	return optimizeContracts(candleStorage, settings, secCodes)
+}
+
+func optimizeContracts(
+	candleStorage domain.ICandleStorage,
+	settings OptimizeSettings,
+	secCodes []string,
+) ([]OptimizationRun, error) {
+	switch settings.Objective {
+	case ObjectiveHpr, ObjectiveSharpe:
+	case ObjectiveDrawdown:
+		if !(settings.MaxDrawdown > 0 && settings.MaxDrawdown < 1) {
+			return nil, fmt.Errorf("maxdrawdown required for objective %v", settings.Objective)
+		}
+	default:
+		return nil, fmt.Errorf("bad objective %v", settings.Objective)
+	}
+
+	var paramSets []advisors.Params
+	if settings.Samples == 0 {
+		paramSets = gridParams(settings.ParamRanges)
+	} else {
+		var rnd = rand.New(rand.NewSource(settings.Seed))
+		paramSets = randomParams(settings.ParamRanges, settings.Samples, rnd)
+	}
+
+	// все наборы параметров проверяем до запуска, чтобы не ждать ошибку в конце
+	var newAdvisors = make([]func() domain.Advisor, len(paramSets))
+	for i, params := range paramSets {
+		newAdvisor, err := advisors.TestAdvisor(settings.AdvisorName, params)
+		if err != nil {
+			return nil, err
+		}
+		newAdvisors[i] = newAdvisor
+	}
+
+	var runs = make([]OptimizationRun, len(paramSets))
+	var index int32 = -1
+	var completed int32 = 0
+	var start = time.Now()
+	var wg = &sync.WaitGroup{}
+	for threadIndex := 0; threadIndex < max(1, settings.Concurrency); threadIndex++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				var i = int(atomic.AddInt32(&index, 1))
+				if i >= len(paramSets) {
+					break
+				}
+				runs[i] = optimizationRun(candleStorage, newAdvisors[i], paramSets[i], secCodes, settings)
+				var n = atomic.AddInt32(&completed, 1)
+				if n%100 == 0 {
+					fmt.Fprintln(os.Stderr, "Completed", n, "of", len(paramSets), time.Since(start))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	sortOptimizationRuns(runs)
+	return runs, nil
+}
+
+func optimizationRun(
+	candleStorage domain.ICandleStorage,
+	newAdvisor func() domain.Advisor,
+	params advisors.Params,
+	secCodes []string,
+	settings OptimizeSettings,
+) OptimizationRun {
+	var run = OptimizationRun{Params: params}
+	var hprs, err = MultiContractHprs(
+		candleStorage, newAdvisor, secCodes, settings.Cost, settings.Roll, settings.Strict, moex.FortsCalendar.IsAfterHolidays, 1)
+	if err != nil {
+		run.Error = err.Error()
+		return run
+	}
+	if len(hprs) == 0 {
+		run.Error = "no data"
+		return run
+	}
+	run.Lever = settings.Lever
+	if run.Lever == 0 {
+		run.Lever = optimalLever(hprs, limitStDev(defaultStDevLimit))
+	}
+	hprs = hprsWithLever(hprs, run.Lever)
+	run.Days = len(hprs)
+	run.TotalHpr = totalHpr(hprs)
+	run.MonthHpr = math.Pow(run.TotalHpr, 22.0/float64(len(hprs)))
+	run.Sharpe = sharpeRatio(hprs)
+	run.MaxDrawdown = computeDrawdownInfo(hprs).MaxDrawdown
+	run.Feasible = true
+	switch settings.Objective {
+	case ObjectiveHpr:
+		run.Objective = run.TotalHpr
+	case ObjectiveSharpe:
+		run.Objective = run.Sharpe
+	case ObjectiveDrawdown:
+		run.Objective = run.MonthHpr
+		run.Feasible = run.MaxDrawdown >= 1-settings.MaxDrawdown
+	}
+	return run
+}
+
+// Лучшие прогоны первыми. Неудачные и нарушающие ограничения в конце.
+func sortOptimizationRuns(runs []OptimizationRun) {
+	sort.SliceStable(runs, func(i, j int) bool {
+		var l, r = &runs[i], &runs[j]
+		if (l.Error == "") != (r.Error == "") {
+			return l.Error == ""
+		}
+		if l.Feasible != r.Feasible {
+			return l.Feasible
+		}
+		return l.Objective > r.Objective
+	})
+}
+
+func PrintOptimizationRuns(runs []OptimizationRun, top int) {
+	var w = newTabWriter(os.Stdout)
+	fmt.Fprintf(w, "Параметры\tПлечо\tДоходность\tВ месяц\tШарп\tПросадка\tЦель\t\n")
+	for _, run := range runs[:min(top, len(runs))] {
+		if run.Error != "" {
+			fmt.Fprintf(w, "%v\t%v\t\t\t\t\t\t\n", run.Params, run.Error)
+			continue
+		}
+		var mark = ""
+		if !run.Feasible {
+			mark = "!"
+		}
+		fmt.Fprintf(w, "%v\t%.1f\t%.1f%%\t%.1f%%\t%.2f\t%.1f%%\t%.4f%v\t\n",
+			run.Params, run.Lever, hprPercent(run.TotalHpr), hprPercent(run.MonthHpr),
+			run.Sharpe, hprPercent(run.MaxDrawdown), run.Objective, mark)
+	}
+	w.Flush()
+}
+
+// Формат определяется по расширению (см. writeJSONOrCSV)
+func WriteOptimizationRuns(path string, runs []OptimizationRun) error {
+	var paramNames []string
+	var seen = make(map[string]bool)
+	for _, run := range runs {
+		for name := range run.Params {
+			if !seen[name] {
+				seen[name] = true
+				paramNames = append(paramNames, name)
+			}
+		}
+	}
+	sort.Strings(paramNames)
+
+	var header = append(append([]string{}, paramNames...),
+		"lever", "total_hpr", "month_hpr", "sharpe", "max_drawdown", "days", "objective", "feasible", "error")
+	var rows [][]string
+	for _, run := range runs {
+		var record []string
+		for _, name := range paramNames {
+			record = append(record, formatFloat(run.Params[name]))
+		}
+		record = append(record,
+			formatFloat(run.Lever),
+			formatFloat(run.TotalHpr),
+			formatFloat(run.MonthHpr),
+			formatFloat(run.Sharpe),
+			formatFloat(run.MaxDrawdown),
+			strconv.Itoa(run.Days),
+			formatFloat(run.Objective),
+			strconv.FormatBool(run.Feasible),
+			run.Error)
+		rows = append(rows, record)
+	}
+	return writeJSONOrCSV(path, runs, header, rows)
+}
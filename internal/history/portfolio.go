@@ -0,0 +1,228 @@
+package history
+
+import (
+	advisors "advisordev/internal/advisors_sample"
+	"advisordev/internal/domain"
+	"advisordev/internal/moex"
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Составляющая портфеля, аналог Signal в trader.xml
+type PortfolioComponent struct {
+	AdvisorName   string
+	AdvisorParams advisors.Params
+	SecurityName  string
+	// Доля плеча портфеля, как Signal.Weight
+	Weight float64
+}
+
+func (c PortfolioComponent) String() string {
+	var name = c.AdvisorName
+	if len(c.AdvisorParams) != 0 {
+		name += "(" + c.AdvisorParams.String() + ")"
+	}
+	return fmt.Sprintf("%v/%v x%v", name, c.SecurityName, c.Weight)
+}
+
+// Разбирает строку вида "ma:Si:0.5:fast=20,slow=100;main:CNY:0.5".
+// Составляющие разделяются ';', поля - ':', параметры необязательны.
+func ParsePortfolioComponents(s string) ([]PortfolioComponent, error) {
+	var result []PortfolioComponent
+	for _, item := range strings.Split(s, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		var parts = strings.SplitN(item, ":", 4)
+		if len(parts) < 3 {
+			return nil, fmt.Errorf("bad portfolio component %q: expected advisor:security:weight[:params]", item)
+		}
+		weight, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad portfolio component %q: %w", item, err)
+		}
+		var params advisors.Params
+		if len(parts) == 4 {
+			params, err = advisors.ParseParams(parts[3])
+			if err != nil {
+				return nil, err
+			}
+		}
+		result = append(result, PortfolioComponent{
+			AdvisorName:   parts[0],
+			AdvisorParams: params,
+			SecurityName:  parts[1],
+			Weight:        weight,
+		})
+	}
+	return result, nil
+}
+
+type PortfolioSettings struct {
+	Components []PortfolioComponent
+	// Общее плечо портфеля. Если 0, то подбирается optimalLever.
+	Lever         float64
+	Cost          CostModel
+	Roll          RollSettings
+	Strict        bool
+	TimeRange     moex.TimeRange
+	MultiContract bool
+}
+
+type PortfolioComponentResult struct {
+	Component PortfolioComponent
+	// Доходности без плеча и веса
+	Hprs []DateSum
+	// Сумма дневных доходностей составляющей с плечом портфеля и весом.
+	// Сумма вкладов равна сумме дневных доходностей портфеля.
+	Contribution float64
+}
+
+type PortfolioResult struct {
+	Components []PortfolioComponentResult
+	Lever      float64
+	// Доходности портфеля с плечом
+	Hprs []DateSum
+	// Корреляции дневных доходностей составляющих по общим дням
+	Correlation [][]float64
+}
+
+func PortfolioBacktest(
+	candleStorage domain.ICandleStorage,
+	settings PortfolioSettings,
+) (PortfolioResult, error) {
+	if len(settings.Components) == 0 {
+		return PortfolioResult{}, fmt.Errorf("portfolio is empty")
+	}
+
+	// параметры всех составляющих проверяем до долгих расчетов
+	var newAdvisors = make([]func() domain.Advisor, len(settings.Components))
+	for i, component := range settings.Components {
+		newAdvisor, err := advisors.TestAdvisor(component.AdvisorName, component.AdvisorParams)
+		if err != nil {
+			return PortfolioResult{}, fmt.Errorf("%v: %w", component, err)
+		}
+		newAdvisors[i] = newAdvisor
+	}
+
+	var result PortfolioResult
+	for i, component := range settings.Components {
+		var secCodes = securityCodes(component.SecurityName, settings.TimeRange, settings.MultiContract)
+		hprs, err := MultiContractHprs(
+			candleStorage, newAdvisors[i], secCodes, settings.Cost, settings.Roll, settings.Strict, moex.FortsCalendar.IsAfterHolidays, runtime.NumCPU())
+		if err != nil {
+			return PortfolioResult{}, fmt.Errorf("%v: %w", component, err)
+		}
+		if len(hprs) == 0 {
+			return PortfolioResult{}, fmt.Errorf("%v: no data", component)
+		}
+		result.Components = append(result.Components, PortfolioComponentResult{
+			Component: component,
+			Hprs:      hprs,
+		})
+	}
+
+	var hprs = combineHprs(result.Components)
+	result.Lever = settings.Lever
+	if result.Lever == 0 {
+		result.Lever = optimalLever(hprs, limitStDev(defaultStDevLimit))
+	}
+	result.Hprs = hprsWithLever(hprs, result.Lever)
+
+	for i := range result.Components {
+		var component = &result.Components[i]
+		for _, hpr := range component.Hprs {
+			component.Contribution += result.Lever * component.Component.Weight * (hpr.Sum - 1)
+		}
+	}
+
+	result.Correlation = correlationMatrix(result.Components)
+	return result, nil
+}
+
+// Сумма взвешенных дневных доходностей. В дни без данных составляющая не дает доходности.
+func combineHprs(components []PortfolioComponentResult) []DateSum {
+	var sums = make(map[time.Time]float64)
+	for _, component := range components {
+		for _, hpr := range component.Hprs {
+			sums[hpr.Date] += component.Component.Weight * (hpr.Sum - 1)
+		}
+	}
+	var result = make([]DateSum, 0, len(sums))
+	for date, sum := range sums {
+		result = append(result, DateSum{Date: date, Sum: 1 + sum})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Date.Before(result[j].Date)
+	})
+	return result
+}
+
+func correlationMatrix(components []PortfolioComponentResult) [][]float64 {
+	var byDates = make([]map[time.Time]float64, len(components))
+	for i, component := range components {
+		byDates[i] = make(map[time.Time]float64, len(component.Hprs))
+		for _, hpr := range component.Hprs {
+			byDates[i][hpr.Date] = math.Log(hpr.Sum)
+		}
+	}
+	var result = make([][]float64, len(components))
+	for i := range components {
+		result[i] = make([]float64, len(components))
+		for j := range components {
+			if i == j {
+				result[i][j] = 1
+				continue
+			}
+			var x, y []float64
+			for date, value := range byDates[i] {
+				if other, found := byDates[j][date]; found {
+					x = append(x, value)
+					y = append(y, other)
+				}
+			}
+			result[i][j] = correlation(x, y)
+		}
+	}
+	return result
+}
+
+func PrintPortfolio(result PortfolioResult) {
+	fmt.Printf("Плечо портфеля: %.1f\n", result.Lever)
+	var w = newTabWriter(os.Stdout)
+	fmt.Fprintf(w, "#\tСоставляющая\tДней\tВ месяц\tСКО за день\tВклад\t\n")
+	for i, component := range result.Components {
+		var hprs = hprsWithLever(component.Hprs, result.Lever*component.Component.Weight)
+		fmt.Fprintf(w, "%v\t%v\t%v\t%.1f%%\t%.1f%%\t%.1f%%\t\n",
+			i+1, component.Component, len(hprs),
+			hprPercent(math.Pow(totalHpr(hprs), 22.0/float64(len(hprs)))),
+			stDevHprs(hprs)*100,
+			component.Contribution*100)
+	}
+	w.Flush()
+
+	fmt.Println("Корреляции дневных доходностей")
+	w = newTabWriter(os.Stdout)
+	fmt.Fprint(w, "\t")
+	for i := range result.Components {
+		fmt.Fprintf(w, "%v\t", i+1)
+	}
+	fmt.Fprintln(w)
+	for i, row := range result.Correlation {
+		fmt.Fprintf(w, "%v\t", i+1)
+		for _, value := range row {
+			fmt.Fprintf(w, "%.2f\t", value)
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+
+	ReportDailyResults(result.Hprs)
+}
@@ -0,0 +1,197 @@
+package history
+
+import (
+	advisors "advisordev/internal/advisors_sample"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	ReportFormatText = "text"
+	ReportFormatJson = "json"
+	ReportFormatCsv  = "csv"
+	ReportFormatHtml = "html"
+)
+
+// Результаты тестирования советника для вывода в отчет
+type Report struct {
+	AdvisorName   string
+	AdvisorParams advisors.Params `json:",omitempty"`
+	SecurityName  string
+	Lever         float64
+	// nil, если плечо задано явно
+	Sizing     *SizingResult `json:",omitempty"`
+	Statistics HprStatistcs
+	// Скользящие окна 3, 6 и 12 месяцев
+	Rolling []RollingWindow `json:",omitempty"`
+	// По дням недели, месяцам года и, если считались сделки, часу входа
+	Breakdowns []Breakdown `json:",omitempty"`
+	// nil, если сравнение с эталоном не запрашивалось
+	Benchmark *BenchmarkStatistics `json:",omitempty"`
+	// nil, если симуляция исполнения не запрашивалась
+	Execution *ExecutionResult `json:",omitempty"`
+	// nil, если статистика по сделкам не запрашивалась
+	Trades *TradeStatistics `json:",omitempty"`
+	// nil, если перебор издержек не запрашивался
+	Sensitivity *SensitivityResult `json:",omitempty"`
+	// nil, если Монте-Карло не запрашивался
+	MonteCarlo *MonteCarloResult `json:",omitempty"`
+	// Переходы между контрактами при склейке
+	Rolls []Roll `json:",omitempty"`
+	// Данные по контрактам и периоды без данных
+	Coverage []ContractCoverage `json:",omitempty"`
+	Missing  []DateSpan         `json:",omitempty"`
+}
+
+type ReportWriter func(w io.Writer, report Report) error
+
+func NewReportWriter(format string) (ReportWriter, error) {
+	switch format {
+	case ReportFormatText:
+		return writeTextReport, nil
+	case ReportFormatJson:
+		return writeJsonReport, nil
+	case ReportFormatCsv:
+		return writeCsvReport, nil
+	case ReportFormatHtml:
+		return writeHtmlReport, nil
+	}
+	return nil, fmt.Errorf("bad report format %q", format)
+}
+
+// Формат по расширению файла, по умолчанию текст
+func ReportFormatByPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return ReportFormatJson
+	case ".csv":
+		return ReportFormatCsv
+	case ".html", ".htm":
+		return ReportFormatHtml
+	}
+	return ReportFormatText
+}
+
+func writeTextReport(w io.Writer, report Report) error {
+	fmt.Fprintln(w, "Отчет", report.AdvisorName, report.SecurityName)
+	if len(report.AdvisorParams) != 0 {
+		fmt.Fprintln(w, "Параметры:", report.AdvisorParams)
+	}
+	fmt.Fprintf(w, "Плечо: %.1f\n", report.Lever)
+	if len(report.Coverage) > 1 || len(report.Missing) != 0 {
+		printReportTable(w, coverageTable(report.Coverage, report.Missing))
+	}
+	printHprReport(w, report.Statistics)
+	if len(report.Rolling) != 0 {
+		printReportTable(w, rollingTable(report.Rolling))
+	}
+	for _, breakdown := range report.Breakdowns {
+		printReportTable(w, breakdownTable(breakdown))
+	}
+	if report.Benchmark != nil {
+		printReportTable(w, benchmarkTable(*report.Benchmark))
+	}
+	if report.Execution != nil {
+		printReportTable(w, executionTable(report.Statistics.DayHprs, *report.Execution))
+	}
+	if report.Trades != nil {
+		printReportTable(w, tradeStatisticsTable(*report.Trades))
+	}
+	if report.Sensitivity != nil {
+		for _, table := range sensitivityTables(*report.Sensitivity) {
+			printReportTable(w, table)
+		}
+	}
+	if report.MonteCarlo != nil {
+		printReportTable(w, monteCarloTable(*report.MonteCarlo))
+	}
+	if len(report.Rolls) != 0 {
+		printReportTable(w, rollsTable(report.Rolls))
+	}
+	if report.Sizing != nil {
+		printReportTable(w, sizingTable(*report.Sizing))
+	}
+	return nil
+}
+
+func writeJsonReport(w io.Writer, report Report) error {
+	var encoder = json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// Дневные доходности с эквити и просадкой от максимума, эквити эталона, если он задан,
+// и показатели скользящих окон (пусто, пока окно не заполнено)
+func writeCsvReport(w io.Writer, report Report) error {
+	var writer = csv.NewWriter(w)
+	var header = []string{"date", "hpr", "equity", "drawdown"}
+	var benchmark []float64
+	if report.Benchmark != nil {
+		header = append(header, "benchmark_equity")
+		benchmark = benchmarkEquity(report.Statistics.DayHprs, report.Benchmark.Hprs)
+	}
+	var rolling = make([]map[time.Time]RollingPoint, len(report.Rolling))
+	for i, window := range report.Rolling {
+		rolling[i] = make(map[time.Time]RollingPoint, len(window.Points))
+		for _, p := range window.Points {
+			rolling[i][p.Date] = p
+		}
+		for _, name := range []string{"return", "volatility", "sharpe", "drawdown"} {
+			header = append(header, fmt.Sprintf("%v_%vm", name, window.Months))
+		}
+	}
+	writer.Write(header)
+	for i, item := range equityCurve(report.Statistics.DayHprs) {
+		var record = []string{
+			item.Date.Format(time.DateOnly),
+			formatFloat(item.Hpr),
+			formatFloat(item.Equity),
+			formatFloat(item.Drawdown),
+		}
+		if benchmark != nil {
+			record = append(record, formatFloat(benchmark[i]))
+		}
+		for _, points := range rolling {
+			p, found := points[item.Date]
+			if !found {
+				record = append(record, "", "", "", "")
+				continue
+			}
+			record = append(record,
+				formatFloat(p.Hpr), formatFloat(p.Volatility), formatFloat(p.Sharpe), formatFloat(p.MaxDrawdown))
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+type equityPoint struct {
+	Date   time.Time
+	Hpr    float64
+	Equity float64
+	// Отношение эквити к предыдущему максимуму, 1 - нет просадки
+	Drawdown float64
+}
+
+func equityCurve(hprs []DateSum) []equityPoint {
+	var result = make([]equityPoint, len(hprs))
+	var equity, highEquity = 1.0, 1.0
+	for i, hpr := range hprs {
+		equity *= hpr.Sum
+		highEquity = math.Max(highEquity, equity)
+		result[i] = equityPoint{
+			Date:     hpr.Date,
+			Hpr:      hpr.Sum,
+			Equity:   equity,
+			Drawdown: equity / highEquity,
+		}
+	}
+	return result
+}
@@ -0,0 +1,25 @@
+package history
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEquityCurve(t *testing.T) {
+	var curve = equityCurve(testHprs(1.1, 0.5, 2, 1.2))
+	var expected = []struct {
+		equity   float64
+		drawdown float64
+	}{
+		{equity: 1.1, drawdown: 1},
+		{equity: 0.55, drawdown: 0.5},
+		{equity: 1.1, drawdown: 1},
+		{equity: 1.32, drawdown: 1},
+	}
+	for i, test := range expected {
+		if math.Abs(curve[i].Equity-test.equity) > 1e-9 ||
+			math.Abs(curve[i].Drawdown-test.drawdown) > 1e-9 {
+			t.Error(i, curve[i])
+		}
+	}
+}
@@ -0,0 +1,356 @@
+package history
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	chartWidth  = 900
+	chartHeight = 260
+	chartMargin = 50
+)
+
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<title>Отчет {{.Report.AdvisorName}} {{.Report.SecurityName}}</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; margin: 20px; color: #222; }
+h1 { font-size: 20px; }
+h2 { font-size: 16px; margin-top: 24px; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 3px 8px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+svg text { font-size: 11px; fill: #444; }
+</style>
+</head>
+<body>
+<h1>Отчет {{.Report.AdvisorName}} {{.Report.SecurityName}}</h1>
+{{if .Report.AdvisorParams}}<p>Параметры: {{.Report.AdvisorParams}}</p>{{end}}
+<p>Плечо: {{printf "%.1f" .Report.Lever}}</p>
+{{range .Tables}}
+{{if .Title}}<h2>{{.Title}}</h2>{{end}}
+<table>
+{{if .Header}}<tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr>{{end}}
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+{{end}}
+<h2>Эквити</h2>
+{{.EquityChart}}
+<h2>Просадка</h2>
+{{.DrawdownChart}}
+<h2>Доходности по месяцам</h2>
+{{.MonthHeatmap}}
+{{if .RollingChart}}<h2>Доходность за скользящие окна</h2>
+{{.RollingChart}}{{end}}
+{{if .LeverChart}}<h2>Доходность в месяц и просадка от плеча</h2>
+{{.LeverChart}}{{end}}
+</body>
+</html>
+`))
+
+// Самодостаточная страница: стили и графики (svg) встроены, внешних ресурсов нет
+func writeHtmlReport(w io.Writer, report Report) error {
+	var tables []reportTable
+	if len(report.Coverage) > 1 || len(report.Missing) != 0 {
+		tables = append(tables, coverageTable(report.Coverage, report.Missing))
+	}
+	tables = append(tables, hprSummaryTable(report.Statistics))
+	tables = append(tables, reportTable{
+		Title:  "Доходности по годам",
+		Header: []string{"Год", "Доходность"},
+		Rows:   hprRows(report.Statistics.YearHprs, "2006"),
+	})
+	if len(report.Rolling) != 0 {
+		tables = append(tables, rollingTable(report.Rolling))
+	}
+	for _, breakdown := range report.Breakdowns {
+		tables = append(tables, breakdownTable(breakdown))
+	}
+	if report.Execution != nil {
+		tables = append(tables, executionTable(report.Statistics.DayHprs, *report.Execution))
+	}
+	if report.Trades != nil {
+		tables = append(tables, tradeStatisticsTable(*report.Trades))
+	}
+	if report.Sensitivity != nil {
+		tables = append(tables, sensitivityTables(*report.Sensitivity)...)
+	}
+	if report.MonteCarlo != nil {
+		tables = append(tables, monteCarloTable(*report.MonteCarlo))
+	}
+	if len(report.Rolls) != 0 {
+		tables = append(tables, rollsTable(report.Rolls))
+	}
+	var leverChart template.HTML
+	if report.Sizing != nil {
+		tables = append(tables, sizingTable(*report.Sizing))
+		leverChart = leverCurveChart(*report.Sizing)
+	}
+	var curve = equityCurve(report.Statistics.DayHprs)
+	var dates = make([]time.Time, len(curve))
+	var equity = make([]float64, len(curve))
+	var drawdown = make([]float64, len(curve))
+	for i, p := range curve {
+		dates[i] = p.Date
+		equity[i] = p.Equity
+		drawdown[i] = p.Drawdown
+	}
+	var equitySeries = []chartSeries{{Name: "Советник", Values: equity, Color: "#2a6ebb"}}
+	if report.Benchmark != nil {
+		tables = append(tables, benchmarkTable(*report.Benchmark))
+		equitySeries = append(equitySeries, chartSeries{
+			Name:   report.Benchmark.SecurityName,
+			Values: benchmarkEquity(report.Statistics.DayHprs, report.Benchmark.Hprs),
+			Color:  "#999",
+		})
+	}
+	return htmlReportTemplate.Execute(w, struct {
+		Report        Report
+		Tables        []reportTable
+		EquityChart   template.HTML
+		DrawdownChart template.HTML
+		MonthHeatmap  template.HTML
+		RollingChart  template.HTML
+		LeverChart    template.HTML
+	}{
+		Report:        report,
+		Tables:        tables,
+		EquityChart:   lineChart(dates, equitySeries...),
+		DrawdownChart: lineChart(dates, chartSeries{Values: drawdown, Color: "#c0392b"}),
+		MonthHeatmap:  monthHeatmap(report.Statistics.MonthHprs),
+		RollingChart:  rollingChart(report.Rolling),
+		LeverChart:    leverChart,
+	})
+}
+
+func hprRows(source []DateSum, layout string) [][]string {
+	var result = make([][]string, len(source))
+	for i, item := range source {
+		result[i] = []string{item.Date.Format(layout), fmt.Sprintf("%.1f%%", hprPercent(item.Sum))}
+	}
+	return result
+}
+
+type chartSeries struct {
+	// Подпись в легенде, если пусто, то легенды нет
+	Name   string
+	Values []float64
+	Color  string
+}
+
+// График значений по дням. Значения в долях (1 - 100%), подписи оси в процентах от 1.
+func lineChart(dates []time.Time, series ...chartSeries) template.HTML {
+	if len(dates) == 0 {
+		return ""
+	}
+	var minValue, maxValue = 1.0, 1.0
+	for _, s := range series {
+		for _, v := range s.Values {
+			minValue = math.Min(minValue, v)
+			maxValue = math.Max(maxValue, v)
+		}
+	}
+	if maxValue == minValue {
+		maxValue = minValue + 0.01
+	}
+	var plotWidth = float64(chartWidth - 2*chartMargin)
+	var plotHeight = float64(chartHeight - 2*chartMargin)
+	var x = func(i int) float64 {
+		if len(dates) == 1 {
+			return chartMargin
+		}
+		return chartMargin + plotWidth*float64(i)/float64(len(dates)-1)
+	}
+	var y = func(v float64) float64 {
+		return chartMargin + plotHeight*(maxValue-v)/(maxValue-minValue)
+	}
+
+	var sb = &strings.Builder{}
+	fmt.Fprintf(sb, `<svg width="%v" height="%v" xmlns="http://www.w3.org/2000/svg">`, chartWidth, chartHeight)
+	var levels = []float64{minValue, maxValue}
+	if minValue < 1 && maxValue > 1 {
+		levels = append(levels, 1)
+	}
+	for _, v := range levels {
+		fmt.Fprintf(sb, `<line x1="%v" y1="%.1f" x2="%v" y2="%.1f" stroke="#ddd"/>`,
+			chartMargin, y(v), chartWidth-chartMargin, y(v))
+		fmt.Fprintf(sb, `<text x="%v" y="%.1f" text-anchor="end">%.0f%%</text>`,
+			chartMargin-4, y(v)+4, hprPercent(v))
+	}
+	// подписи годов
+	for i, date := range dates {
+		if i == 0 || date.Year() != dates[i-1].Year() {
+			fmt.Fprintf(sb, `<line x1="%.1f" y1="%v" x2="%.1f" y2="%v" stroke="#eee"/>`,
+				x(i), chartMargin, x(i), chartHeight-chartMargin)
+			fmt.Fprintf(sb, `<text x="%.1f" y="%v">%v</text>`,
+				x(i)+2, chartHeight-chartMargin+14, date.Year())
+		}
+	}
+	// рисуем в обратном порядке, чтобы первый ряд был сверху
+	for j := len(series) - 1; j >= 0; j-- {
+		fmt.Fprintf(sb, `<polyline fill="none" stroke="%v" stroke-width="1.5" points="`, series[j].Color)
+		for i, v := range series[j].Values {
+			fmt.Fprintf(sb, "%.1f,%.1f ", x(i), y(v))
+		}
+		sb.WriteString(`"/>`)
+	}
+	for j, s := range series {
+		if s.Name == "" {
+			continue
+		}
+		// style, а не fill: цвет текста задан в css страницы
+		fmt.Fprintf(sb, `<text x="%v" y="%v" style="fill:%v">%v</text>`,
+			chartMargin+10, 14+j*14, s.Color, template.HTMLEscapeString(s.Name))
+	}
+	sb.WriteString(`</svg>`)
+	return template.HTML(sb.String())
+}
+
+// Тепловая карта: строки - годы, столбцы - месяцы
+func monthHeatmap(monthHprs []DateSum) template.HTML {
+	if len(monthHprs) == 0 {
+		return ""
+	}
+	const cellWidth, cellHeight, labelWidth = 60, 24, 50
+	var firstYear = monthHprs[0].Date.Year()
+	var years = monthHprs[len(monthHprs)-1].Date.Year() - firstYear + 1
+	var maxAbs = 0.0
+	for _, item := range monthHprs {
+		maxAbs = math.Max(maxAbs, math.Abs(item.Sum-1))
+	}
+	if maxAbs == 0 {
+		maxAbs = 1
+	}
+
+	var sb = &strings.Builder{}
+	fmt.Fprintf(sb, `<svg width="%v" height="%v" xmlns="http://www.w3.org/2000/svg">`,
+		labelWidth+12*cellWidth, (years+1)*cellHeight)
+	for m := 0; m < 12; m++ {
+		fmt.Fprintf(sb, `<text x="%v" y="%v" text-anchor="middle">%v</text>`,
+			labelWidth+m*cellWidth+cellWidth/2, cellHeight-8, m+1)
+	}
+	for i := 0; i < years; i++ {
+		fmt.Fprintf(sb, `<text x="0" y="%v">%v</text>`, (i+2)*cellHeight-8, firstYear+i)
+	}
+	for _, item := range monthHprs {
+		var row = item.Date.Year() - firstYear + 1
+		var column = int(item.Date.Month()) - 1
+		var intensity = math.Abs(item.Sum-1) / maxAbs
+		var color = "46,160,67"
+		if item.Sum < 1 {
+			color = "192,57,43"
+		}
+		fmt.Fprintf(sb, `<rect x="%v" y="%v" width="%v" height="%v" fill="rgba(%v,%.2f)" stroke="#fff"/>`,
+			labelWidth+column*cellWidth, row*cellHeight, cellWidth, cellHeight, color, 0.15+0.85*intensity)
+		fmt.Fprintf(sb, `<text x="%v" y="%v" text-anchor="middle">%.1f%%</text>`,
+			labelWidth+column*cellWidth+cellWidth/2, (row+1)*cellHeight-8, hprPercent(item.Sum))
+	}
+	sb.WriteString(`</svg>`)
+	return template.HTML(sb.String())
+}
+
+var rollingColors = []string{"#95c4e8", "#2a6ebb", "#1b3a5c"}
+
+// Доходности всех окон по датам самого длинного окна: все окна заканчиваются в последний день
+func rollingChart(windows []RollingWindow) template.HTML {
+	if len(windows) == 0 {
+		return ""
+	}
+	var n = len(windows[0].Points)
+	for _, window := range windows {
+		n = min(n, len(window.Points))
+	}
+	if n == 0 {
+		return ""
+	}
+	var longest = windows[len(windows)-1].Points
+	var dates = make([]time.Time, n)
+	for i, p := range longest[len(longest)-n:] {
+		dates[i] = p.Date
+	}
+	var series []chartSeries
+	for j, window := range windows {
+		var values = make([]float64, n)
+		for i, p := range window.Points[len(window.Points)-n:] {
+			values[i] = p.Hpr
+		}
+		series = append(series, chartSeries{
+			Name:   fmt.Sprintf("%v мес.", window.Months),
+			Values: values,
+			Color:  rollingColors[j%len(rollingColors)],
+		})
+	}
+	return lineChart(dates, series...)
+}
+
+// Доходность в месяц и максимальная просадка в зависимости от плеча, выбранное плечо отмечено вертикальной линией
+func leverCurveChart(sizing SizingResult) template.HTML {
+	var curve = sizing.Curve
+	if len(curve) == 0 {
+		return ""
+	}
+	var series = []chartSeries{
+		{Name: "Доходность в месяц", Values: make([]float64, len(curve)), Color: "#2a6ebb"},
+		{Name: "Макс. просадка", Values: make([]float64, len(curve)), Color: "#c0392b"},
+	}
+	var minValue, maxValue = 1.0, 1.0
+	for i, p := range curve {
+		series[0].Values[i] = p.MonthHpr
+		series[1].Values[i] = p.MaxDrawdown
+		minValue = math.Min(minValue, math.Min(p.MonthHpr, p.MaxDrawdown))
+		maxValue = math.Max(maxValue, math.Max(p.MonthHpr, p.MaxDrawdown))
+	}
+	if maxValue == minValue {
+		maxValue = minValue + 0.01
+	}
+	var maxLever = curve[len(curve)-1].Lever
+	var plotWidth = float64(chartWidth - 2*chartMargin)
+	var plotHeight = float64(chartHeight - 2*chartMargin)
+	var x = func(lever float64) float64 {
+		return chartMargin + plotWidth*lever/maxLever
+	}
+	var y = func(v float64) float64 {
+		return chartMargin + plotHeight*(maxValue-v)/(maxValue-minValue)
+	}
+
+	var sb = &strings.Builder{}
+	fmt.Fprintf(sb, `<svg width="%v" height="%v" xmlns="http://www.w3.org/2000/svg">`, chartWidth, chartHeight)
+	var levels = []float64{minValue, maxValue}
+	if minValue < 1 && maxValue > 1 {
+		levels = append(levels, 1)
+	}
+	for _, v := range levels {
+		fmt.Fprintf(sb, `<line x1="%v" y1="%.1f" x2="%v" y2="%.1f" stroke="#ddd"/>`,
+			chartMargin, y(v), chartWidth-chartMargin, y(v))
+		fmt.Fprintf(sb, `<text x="%v" y="%.1f" text-anchor="end">%.0f%%</text>`,
+			chartMargin-4, y(v)+4, hprPercent(v))
+	}
+	// подписи плеча
+	for i := 0; i <= 4; i++ {
+		var lever = maxLever * float64(i) / 4
+		fmt.Fprintf(sb, `<text x="%.1f" y="%v" text-anchor="middle">%.1f</text>`,
+			x(lever), chartHeight-chartMargin+14, lever)
+	}
+	fmt.Fprintf(sb, `<line x1="%.1f" y1="%v" x2="%.1f" y2="%v" stroke="#888" stroke-dasharray="4"/>`,
+		x(sizing.Lever), chartMargin, x(sizing.Lever), chartHeight-chartMargin)
+	for j := len(series) - 1; j >= 0; j-- {
+		fmt.Fprintf(sb, `<polyline fill="none" stroke="%v" stroke-width="1.5" points="`, series[j].Color)
+		for i, v := range series[j].Values {
+			fmt.Fprintf(sb, "%.1f,%.1f ", x(curve[i].Lever), y(v))
+		}
+		sb.WriteString(`"/>`)
+	}
+	for j, s := range series {
+		fmt.Fprintf(sb, `<text x="%v" y="%v" style="fill:%v">%v</text>`,
+			chartMargin+10, 14+j*14, s.Color, template.HTMLEscapeString(s.Name))
+	}
+	sb.WriteString(`</svg>`)
+	return template.HTML(sb.String())
+}
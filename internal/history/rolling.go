@@ -0,0 +1,224 @@
+package history
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Длины скользящих окон в месяцах
+var rollingWindowMonths = []int{3, 6, 12}
+
+// Показатели за окно, которое заканчивается днем Date
+type RollingPoint struct {
+	Date time.Time
+	// Доходность за окно
+	Hpr float64
+	// Годовая волатильность логарифмов дневных доходностей
+	Volatility  float64
+	Sharpe      float64
+	MaxDrawdown float64
+}
+
+// Скользящее окно Months месяцев. Точки начинаются, когда данных хватает на все окно.
+type RollingWindow struct {
+	Months int
+	Points []RollingPoint
+}
+
+// Группа дней (или сделок) разбивки: число, средняя и итоговая доходность, доля прибыльных
+type HprGroup struct {
+	Name       string
+	Count      int
+	AvgHpr     float64
+	TotalHpr   float64
+	Profitable float64
+}
+
+// Разбивка доходностей по дням недели, месяцам года или часу входа в сделку
+type Breakdown struct {
+	Title  string
+	Groups []HprGroup
+}
+
+func computeRollingWindows(hprs []DateSum) []RollingWindow {
+	var daysPerYear = tradingDaysPerYear(hprs)
+	var result = make([]RollingWindow, len(rollingWindowMonths))
+	for i, months := range rollingWindowMonths {
+		result[i] = RollingWindow{Months: months, Points: rollingPoints(hprs, months, daysPerYear)}
+	}
+	return result
+}
+
+func rollingPoints(hprs []DateSum, months int, daysPerYear float64) []RollingPoint {
+	var result []RollingPoint
+	// окно hprs[start:i+1] - дни после той же даты months месяцев назад
+	var start = 0
+	for i, hpr := range hprs {
+		var bound = hpr.Date.AddDate(0, -months, 0)
+		for start <= i && !hprs[start].Date.After(bound) {
+			start++
+		}
+		if start == 0 {
+			continue
+		}
+		var window = hprs[start : i+1]
+		var mean, stDev = moments(logHprs(window))
+		var point = RollingPoint{
+			Date:        hpr.Date,
+			Hpr:         totalHpr(window),
+			Volatility:  stDev * math.Sqrt(daysPerYear),
+			MaxDrawdown: computeDrawdownInfo(window).MaxDrawdown,
+		}
+		if stDev != 0 {
+			point.Sharpe = mean / stDev * math.Sqrt(daysPerYear)
+		}
+		result = append(result, point)
+	}
+	return result
+}
+
+var weekdayNames = []string{"Вс", "Пн", "Вт", "Ср", "Чт", "Пт", "Сб"}
+
+var monthNames = []string{"Янв", "Фев", "Мар", "Апр", "Май", "Июн", "Июл", "Авг", "Сен", "Окт", "Ноя", "Дек"}
+
+func weekdayBreakdown(hprs []DateSum) Breakdown {
+	// с понедельника
+	var keys = []int{1, 2, 3, 4, 5, 6, 0}
+	var names = make([]string, len(keys))
+	for i, key := range keys {
+		names[i] = weekdayNames[key]
+	}
+	return Breakdown{
+		Title: "Доходности по дням недели",
+		Groups: groupHprs(hprs, names, func(item DateSum) int {
+			return (int(item.Date.Weekday()) + 6) % 7
+		}),
+	}
+}
+
+func monthOfYearBreakdown(hprs []DateSum) Breakdown {
+	return Breakdown{
+		Title: "Доходности по месяцам года",
+		Groups: groupHprs(hprs, monthNames, func(item DateSum) int {
+			return int(item.Date.Month()) - 1
+		}),
+	}
+}
+
+// Доходности сделок (с учетом плеча) по часу входа
+func entryHourBreakdown(trades []Trade) Breakdown {
+	var hprs = make([]DateSum, len(trades))
+	for i, trade := range trades {
+		hprs[i] = DateSum{Date: trade.EntryTime, Sum: 1 + trade.Pnl}
+	}
+	var names = make([]string, 24)
+	for hour := range names {
+		names[hour] = fmt.Sprintf("%02d", hour)
+	}
+	return Breakdown{
+		Title: "Сделки по часу входа",
+		Groups: groupHprs(hprs, names, func(item DateSum) int {
+			return item.Date.Hour()
+		}),
+	}
+}
+
+// Группирует доходности по индексу группы key в names. Пустые группы пропускаются.
+func groupHprs(hprs []DateSum, names []string, key func(DateSum) int) []HprGroup {
+	var groups = make([]HprGroup, len(names))
+	for i := range groups {
+		groups[i] = HprGroup{Name: names[i], TotalHpr: 1}
+	}
+	for _, item := range hprs {
+		var group = &groups[key(item)]
+		group.Count++
+		group.AvgHpr += item.Sum
+		group.TotalHpr *= item.Sum
+		if item.Sum > 1 {
+			group.Profitable++
+		}
+	}
+	var result []HprGroup
+	for _, group := range groups {
+		if group.Count == 0 {
+			continue
+		}
+		group.AvgHpr /= float64(group.Count)
+		group.Profitable /= float64(group.Count)
+		result = append(result, group)
+	}
+	return result
+}
+
+func breakdownTable(breakdown Breakdown) reportTable {
+	var rows = make([][]string, len(breakdown.Groups))
+	for i, group := range breakdown.Groups {
+		rows[i] = []string{
+			group.Name,
+			fmt.Sprint(group.Count),
+			fmt.Sprintf("%.2f%%", hprPercent(group.AvgHpr)),
+			fmt.Sprintf("%.1f%%", hprPercent(group.TotalHpr)),
+			fmt.Sprintf("%.1f%%", group.Profitable*100),
+		}
+	}
+	return reportTable{
+		Title:  breakdown.Title,
+		Header: []string{"", "Количество", "Средняя", "Итого", "Прибыльных"},
+		Rows:   rows,
+	}
+}
+
+// Последнее, минимальное, медианное и максимальное значение показателей каждого окна
+func rollingTable(windows []RollingWindow) reportTable {
+	var rows [][]string
+	for _, window := range windows {
+		if len(window.Points) == 0 {
+			continue
+		}
+		var metrics = []struct {
+			name   string
+			value  func(RollingPoint) float64
+			format func(float64) string
+		}{
+			{"доходность", func(p RollingPoint) float64 { return p.Hpr }, formatHprPercent},
+			{"волатильность", func(p RollingPoint) float64 { return p.Volatility }, formatPercent},
+			{"Шарп", func(p RollingPoint) float64 { return p.Sharpe }, formatRatio},
+			{"просадка", func(p RollingPoint) float64 { return p.MaxDrawdown }, formatHprPercent},
+		}
+		for _, metric := range metrics {
+			var values = make([]float64, len(window.Points))
+			for i, p := range window.Points {
+				values[i] = metric.value(p)
+			}
+			var sorted = make([]float64, len(values))
+			copy(sorted, values)
+			sort.Float64s(sorted)
+			rows = append(rows, []string{
+				fmt.Sprintf("%v мес. %v", window.Months, metric.name),
+				metric.format(values[len(values)-1]),
+				metric.format(sorted[0]),
+				metric.format(percentile(sorted, 0.5)),
+				metric.format(sorted[len(sorted)-1]),
+			})
+		}
+	}
+	return reportTable{
+		Title:  "Скользящие окна",
+		Header: []string{"", "Последнее", "Мин.", "Медиана", "Макс."},
+		Rows:   rows,
+	}
+}
+
+func formatHprPercent(x float64) string {
+	return fmt.Sprintf("%.1f%%", hprPercent(x))
+}
+
+func formatPercent(x float64) string {
+	return fmt.Sprintf("%.1f%%", x*100)
+}
+
+func formatRatio(x float64) string {
+	return fmt.Sprintf("%.2f", x)
+}
@@ -0,0 +1,57 @@
+package history
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRollingPoints(t *testing.T) {
+	var start = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	var hprs []DateSum
+	for i := 0; i < 120; i++ {
+		var sum = 1.01
+		if i%3 == 2 {
+			sum = 0.99
+		}
+		hprs = append(hprs, DateSum{Date: start.AddDate(0, 0, i), Sum: sum})
+	}
+	var points = rollingPoints(hprs, 3, 250)
+	// первое полное окно заканчивается 01.04: дни 02.01-01.04
+	if len(points) == 0 || !points[0].Date.Equal(time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("first point %+v", points)
+	}
+	if len(points) != len(hprs)-91 {
+		t.Errorf("points %v", len(points))
+	}
+	var last = points[len(points)-1]
+	var window = hprs[len(hprs)-91:]
+	if math.Abs(last.Hpr-totalHpr(window)) > 1e-12 {
+		t.Errorf("hpr %v, want %v", last.Hpr, totalHpr(window))
+	}
+	if math.Abs(last.Volatility-stDevHprs(window)*math.Sqrt(250)) > 1e-12 {
+		t.Errorf("volatility %v", last.Volatility)
+	}
+	if !(last.Sharpe > 0 && last.MaxDrawdown < 1) {
+		t.Errorf("last point %+v", last)
+	}
+}
+
+func TestWeekdayBreakdown(t *testing.T) {
+	// понедельник
+	var start = time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC)
+	var hprs = []DateSum{
+		{Date: start, Sum: 1.02},
+		{Date: start.AddDate(0, 0, 4), Sum: 0.99},
+		{Date: start.AddDate(0, 0, 7), Sum: 0.98},
+	}
+	var groups = weekdayBreakdown(hprs).Groups
+	if len(groups) != 2 || groups[0].Name != "Пн" || groups[1].Name != "Пт" {
+		t.Fatalf("groups %+v", groups)
+	}
+	var monday = groups[0]
+	if monday.Count != 2 || math.Abs(monday.AvgHpr-1) > 1e-12 ||
+		math.Abs(monday.TotalHpr-1.02*0.98) > 1e-12 || monday.Profitable != 0.5 {
+		t.Errorf("monday %+v", monday)
+	}
+}
@@ -0,0 +1,238 @@
+package history
+
+import (
+	"advisordev/internal/moex"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// Переход, когда начинаются данные следующего контракта (последний день предыдущего не берется)
+	RollData = "data"
+	// За Days торговых дней до экспирации
+	RollExpiration = "expiration"
+	// На следующий день после того, как дневной объем следующего контракта превысил объем текущего.
+	// Открытый интерес в хранилище баров не сохраняется, поэтому сравниваются объемы.
+	RollVolume = "volume"
+	// В день Days месяца экспирации (первый торговый день не раньше)
+	RollCalendar = "calendar"
+)
+
+// Правило перехода на следующий контракт при склейке доходностей
+type RollSettings struct {
+	// RollData (по умолчанию), RollExpiration, RollVolume или RollCalendar
+	Rule string
+	Days int
+}
+
+func (s RollSettings) Validate() error {
+	switch s.Rule {
+	case "", RollData, RollVolume:
+	case RollExpiration:
+		if s.Days < 0 {
+			return fmt.Errorf("bad roll days %v", s.Days)
+		}
+	case RollCalendar:
+		if s.Days < 1 || s.Days > 31 {
+			return fmt.Errorf("bad roll day of month %v", s.Days)
+		}
+	default:
+		return fmt.Errorf("bad roll rule %q", s.Rule)
+	}
+	return nil
+}
+
+// Переход с контракта From на To. Позиция закрывается в From и открывается в To в начале дня Date.
+type Roll struct {
+	Date time.Time
+	From string
+	To   string
+	// Издержки перехода (доля цены контракта без плеча), вычтены из доходности дня Date
+	Cost float64
+}
+
+// Доходности и переходы склеенных контрактов
+type MultiContractResult struct {
+	Hprs  []DateSum
+	Rolls []Roll
+	// Сделки склеенных контрактов: сделки, открытые при переходе, обрезаются по дате перехода
+	Trades   []Trade
+	Coverage []ContractCoverage
+	// Периоды без данных, например из-за ошибки загрузки контракта
+	Missing []DateSpan
+}
+
+// Дни [from, to) контракта, которые вошли в склеенные доходности. to < 0 - до конца данных.
+type contractWindow struct {
+	contract int
+	from     int
+	to       int
+}
+
+// Склеивает дневные итоги и сделки контрактов по правилу settings. Контракт не может держаться дольше своих данных:
+// если правило дает более позднюю дату, переход выполняется по RollData. tradesByContracts может быть nil.
+func rollContracts(
+	secCodes []string,
+	daysByContracts [][]contractDay,
+	tradesByContracts [][]Trade,
+	settings RollSettings,
+	cost CostModel,
+) (MultiContractResult, error) {
+	var result MultiContractResult
+	var windows []contractWindow
+	var current = -1
+	for i, days := range daysByContracts {
+		if len(days) == 0 {
+			continue
+		}
+		if current == -1 {
+			result.Hprs = append(result.Hprs, contractDaysHprs(days)...)
+			windows = append(windows, contractWindow{contract: i, from: 0, to: -1})
+			current = i
+			continue
+		}
+		var from = daysByContracts[current]
+		var date = rollDate(settings, secCodes[current], from, days)
+		if len(result.Rolls) != 0 && !date.After(result.Rolls[len(result.Rolls)-1].Date) {
+			// следующий контракт закончился раньше, чем на него перешли
+			continue
+		}
+		var k = sort.Search(len(days), func(k int) bool {
+			return !days[k].Date.Before(date)
+		})
+		if k == len(days) {
+			continue
+		}
+		date = days[k].Date
+
+		var roll = Roll{Date: date, From: secCodes[current], To: secCodes[i]}
+		var oldDay, found = lastDayBefore(from, date)
+		if found {
+			c, err := cost(roll.From, date, oldDay.Price)
+			if err != nil {
+				return MultiContractResult{}, err
+			}
+			roll.Cost += c * math.Abs(oldDay.Position)
+		}
+		var newDay = days[k]
+		if k > 0 {
+			newDay = days[k-1]
+		}
+		c, err := cost(roll.To, date, newDay.Price)
+		if err != nil {
+			return MultiContractResult{}, err
+		}
+		roll.Cost += c * math.Abs(newDay.Position)
+
+		for len(result.Hprs) != 0 && !result.Hprs[len(result.Hprs)-1].Date.Before(date) {
+			result.Hprs = result.Hprs[:len(result.Hprs)-1]
+		}
+		var hprs = contractDaysHprs(days[k:])
+		hprs[0].Sum -= roll.Cost
+		result.Hprs = append(result.Hprs, hprs...)
+		result.Rolls = append(result.Rolls, roll)
+		windows[len(windows)-1].to = sort.Search(len(from), func(k int) bool {
+			return !from[k].Date.Before(date)
+		})
+		windows = append(windows, contractWindow{contract: i, from: k, to: -1})
+		current = i
+	}
+	if tradesByContracts != nil {
+		for _, window := range windows {
+			for _, trade := range tradesByContracts[window.contract] {
+				trade, found, err := trade.window(window.from, window.to, cost)
+				if err != nil {
+					return MultiContractResult{}, err
+				}
+				if found {
+					result.Trades = append(result.Trades, trade)
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+func lastDayBefore(days []contractDay, date time.Time) (contractDay, bool) {
+	var k = sort.Search(len(days), func(k int) bool {
+		return !days[k].Date.Before(date)
+	})
+	if k == 0 {
+		return contractDay{}, false
+	}
+	return days[k-1], true
+}
+
+// Первый день, с которого берется контракт to вместо from
+func rollDate(settings RollSettings, fromCode string, from, to []contractDay) time.Time {
+	// последний день предыдущего контракта может быть не полный
+	var dataDate time.Time
+	if len(from) >= 2 {
+		var last = from[len(from)-2].Date
+		for _, day := range to {
+			if day.Date.After(last) {
+				dataDate = day.Date
+				break
+			}
+		}
+		if dataDate.IsZero() {
+			dataDate = last.AddDate(0, 0, 1)
+		}
+	}
+	var date = dataDate
+	switch settings.Rule {
+	case RollExpiration:
+		var expiration, err = moex.ExpirationDate(fromCode)
+		if err != nil {
+			break
+		}
+		var k = sort.Search(len(from), func(k int) bool {
+			return !from[k].Date.Before(expiration)
+		})
+		if settings.Days == 0 {
+			date = expiration
+		} else if k >= settings.Days {
+			date = from[k-settings.Days].Date
+		}
+	case RollVolume:
+		var volumes = make(map[time.Time]float64, len(to))
+		for _, day := range to {
+			volumes[day.Date] = day.Volume
+		}
+		for _, day := range from {
+			if volumes[day.Date] > day.Volume {
+				date = day.Date.AddDate(0, 0, 1)
+				break
+			}
+		}
+	case RollCalendar:
+		var expiration, err = moex.ExpirationDate(fromCode)
+		if err != nil {
+			break
+		}
+		date = time.Date(expiration.Year(), expiration.Month(), settings.Days, 0, 0, 0, 0, expiration.Location())
+	}
+	if !dataDate.IsZero() && date.After(dataDate) {
+		date = dataDate
+	}
+	return date
+}
+
+func rollsTable(rolls []Roll) reportTable {
+	var rows = make([][]string, len(rolls))
+	for i, roll := range rolls {
+		rows[i] = []string{
+			roll.Date.Format("02.01.2006"),
+			roll.From,
+			roll.To,
+			fmt.Sprintf("%.3f%%", roll.Cost*100),
+		}
+	}
+	return reportTable{
+		Title:  "Переходы на следующий контракт",
+		Header: []string{"Дата", "С контракта", "На контракт", "Издержки"},
+		Rows:   rows,
+	}
+}
@@ -0,0 +1,111 @@
+package history
+
+import (
+	"advisordev/internal/moex"
+	"math"
+	"testing"
+	"time"
+)
+
+// n дней контракта подряд, начиная с from
+func testContractDays(from time.Time, n int, volume func(int) float64) []contractDay {
+	var result []contractDay
+	for i := 0; i < n; i++ {
+		result = append(result, contractDay{
+			DateSum:  DateSum{Date: from.AddDate(0, 0, i), Sum: 1.01},
+			Position: 1,
+			Price:    100,
+			Volume:   volume(i),
+		})
+	}
+	return result
+}
+
+func TestRollContracts(t *testing.T) {
+	var date = func(day int) time.Time {
+		return time.Date(2025, time.March, day, 0, 0, 0, 0, moex.TimeZone)
+	}
+	var secCodes = []string{"Si-3.25", "Si-6.25"}
+	var daysByContracts = [][]contractDay{
+		testContractDays(date(1), 20, func(i int) float64 { return 100 }),
+		testContractDays(date(5), 25, func(i int) float64 { return float64(i * 10) }),
+	}
+	var tests = []struct {
+		settings RollSettings
+		rollDate time.Time
+	}{
+		{RollSettings{}, date(20)},
+		// экспирация Si-3.25 - 20.03, 3-й четверг
+		{RollSettings{Rule: RollExpiration, Days: 3}, date(17)},
+		{RollSettings{Rule: RollExpiration, Days: 0}, date(20)},
+		// объем следующего контракта больше со дня 05.03+11
+		{RollSettings{Rule: RollVolume}, date(17)},
+		{RollSettings{Rule: RollCalendar, Days: 10}, date(10)},
+		// позже данных контракта держать нельзя
+		{RollSettings{Rule: RollCalendar, Days: 25}, date(20)},
+	}
+	for _, test := range tests {
+		result, err := rollContracts(secCodes, daysByContracts, nil, test.settings, ConstantCost(0.001))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Rolls) != 1 || !result.Rolls[0].Date.Equal(test.rollDate) {
+			t.Errorf("%+v: rolls %+v, want %v", test.settings, result.Rolls, test.rollDate)
+			continue
+		}
+		if math.Abs(result.Rolls[0].Cost-0.002) > 1e-9 {
+			t.Errorf("%+v: roll cost %v", test.settings, result.Rolls[0].Cost)
+		}
+		// без пропусков и повторов дат
+		for i, hpr := range result.Hprs {
+			if !hpr.Date.Equal(date(1).AddDate(0, 0, i)) {
+				t.Fatalf("%+v: bad date %v at %v", test.settings, hpr.Date, i)
+			}
+			var want = 1.01
+			if hpr.Date.Equal(test.rollDate) {
+				want -= 0.002
+			}
+			if math.Abs(hpr.Sum-want) > 1e-9 {
+				t.Errorf("%+v: hpr %v at %v", test.settings, hpr.Sum, hpr.Date)
+			}
+		}
+	}
+}
+
+func TestOutSampleHprs(t *testing.T) {
+	var date = func(day int) time.Time {
+		return time.Date(2025, time.March, day, 0, 0, 0, 0, moex.TimeZone)
+	}
+	var contracts = MultiContractResult{
+		Hprs: testHprs(1, 1, 1, 1, 1, 1, 1, 1, 1, 1),
+		Rolls: []Roll{
+			{Date: date(3), From: "Si-12.24", To: "Si-3.25"},
+			{Date: date(8), From: "Si-3.25", To: "Si-6.25"},
+		},
+		Coverage: []ContractCoverage{{SecurityCode: "Si-12.24", Days: 5}},
+	}
+	for i := range contracts.Hprs {
+		contracts.Hprs[i].Date = date(i + 1)
+	}
+	var tests = []struct {
+		prev, next string
+		from, to   int
+	}{
+		{"Si-12.24", "Si-6.25", 3, 7},
+		{"Si-12.24", "", 3, 10},
+		{"Si-9.24", "", 0, 0},
+	}
+	for _, test := range tests {
+		var hprs = outSampleHprs(contracts, test.prev, test.next)
+		if test.from == 0 {
+			if len(hprs) != 0 {
+				t.Error(test, hprs)
+			}
+			continue
+		}
+		if len(hprs) != test.to-test.from+1 || !hprs[0].Date.Equal(date(test.from)) ||
+			!hprs[len(hprs)-1].Date.Equal(date(test.to)) {
+			t.Error(test, hprs)
+		}
+	}
+}
@@ -0,0 +1,531 @@
+package history
+
+import (
+	advisors "advisordev/internal/advisors_sample"
+	"advisordev/internal/domain"
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	runInfoFileName  = "run.json"
+	runHprsFileName  = "hprs.csv"
+	runPatchFileName = "changes.patch"
+	runIDLayout      = "20060102-150405"
+)
+
+// Прогон отчета в хранилище: параметры, ревизия кода, отпечаток данных и итоги.
+// Дневные доходности (с плечом) лежат рядом в hprs.csv.
+type RunInfo struct {
+	ID            string
+	Time          time.Time
+	Args          []string `json:",omitempty"`
+	AdvisorName   string
+	AdvisorParams advisors.Params `json:",omitempty"`
+	SecurityName  string
+	SecurityCodes []string
+	Lever         float64
+	// Ревизия git, с суффиксом -dirty при незакоммиченных изменениях (они сохраняются в changes.patch)
+	Revision string
+	// Хеш баров всех контрактов
+	DataFingerprint string
+	MonthHpr        float64
+	AnnualHpr       float64
+	MaxDrawdown     float64
+	Sharpe          float64
+	Days            int
+}
+
+type Run struct {
+	RunInfo
+	Hprs []DateSum
+}
+
+// Сохраняет прогон в новый каталог dir/ID. fingerprint - хеш баров контрактов (fingerprintStorage).
+func SaveRun(
+	dir string,
+	fingerprint string,
+	secCodes []string,
+	report Report,
+	args []string,
+) (RunInfo, error) {
+	var now = time.Now()
+	var info = RunInfo{
+		Time:            now,
+		Args:            args,
+		AdvisorName:     report.AdvisorName,
+		AdvisorParams:   report.AdvisorParams,
+		SecurityName:    report.SecurityName,
+		SecurityCodes:   secCodes,
+		Lever:           report.Lever,
+		DataFingerprint: fingerprint,
+		MonthHpr:        report.Statistics.MonthHpr,
+		AnnualHpr:       report.Statistics.AnnualHpr,
+		MaxDrawdown:     report.Statistics.DrawdownInfo.MaxDrawdown,
+		Sharpe:          report.Statistics.Sharpe,
+		Days:            len(report.Statistics.DayHprs),
+	}
+	var patch []byte
+	info.Revision, patch = sourceRevision()
+
+	var err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return RunInfo{}, err
+	}
+	// несколько прогонов в одну секунду получают суффикс
+	var runDir string
+	for i := 1; ; i++ {
+		info.ID = now.Format(runIDLayout)
+		if i > 1 {
+			info.ID += fmt.Sprintf("-%v", i)
+		}
+		runDir = filepath.Join(dir, info.ID)
+		err = os.Mkdir(runDir, 0755)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return RunInfo{}, err
+		}
+	}
+
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return RunInfo{}, err
+	}
+	err = os.WriteFile(filepath.Join(runDir, runInfoFileName), data, 0644)
+	if err != nil {
+		return RunInfo{}, err
+	}
+	if len(patch) != 0 {
+		err = os.WriteFile(filepath.Join(runDir, runPatchFileName), patch, 0644)
+		if err != nil {
+			return RunInfo{}, err
+		}
+	}
+	err = writeRunHprs(filepath.Join(runDir, runHprsFileName), report.Statistics.DayHprs)
+	if err != nil {
+		return RunInfo{}, err
+	}
+	return info, nil
+}
+
+func writeRunHprs(path string, hprs []DateSum) error {
+	var file, err = os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var writer = csv.NewWriter(file)
+	writer.Write([]string{"date", "hpr"})
+	for _, item := range hprs {
+		writer.Write([]string{item.Date.Format(time.DateOnly), formatFloat(item.Sum)})
+	}
+	writer.Flush()
+	if err = writer.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func readRunHprs(path string) ([]DateSum, error) {
+	var file, err = os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var result []DateSum
+	for i, record := range records {
+		if i == 0 {
+			continue
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("%v: bad line %v", path, i+1)
+		}
+		date, err := time.Parse(time.DateOnly, record[0])
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", path, err)
+		}
+		sum, err := strconv.ParseFloat(record[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", path, err)
+		}
+		result = append(result, DateSum{Date: date, Sum: sum})
+	}
+	return result, nil
+}
+
+// Ревизия кода из сборки, а если ее там нет (go run), то из git, но только если текущий каталог - этот модуль.
+// При незакоммиченных изменениях git возвращается и их diff.
+func sourceRevision() (string, []byte) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "", nil
+	}
+	var revision, modified string
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value
+		}
+	}
+	if revision != "" {
+		if modified == "true" {
+			revision += "-dirty"
+		}
+		return revision, nil
+	}
+	if !isModuleRepository(info.Main.Path) {
+		return "", nil
+	}
+	out, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", nil
+	}
+	revision = strings.TrimSpace(string(out))
+	patch, err := exec.Command("git", "diff", "HEAD").Output()
+	if err == nil && len(bytes.TrimSpace(patch)) != 0 {
+		return revision + "-dirty", patch
+	}
+	return revision, nil
+}
+
+// Является ли репозиторий git текущего каталога модулем modulePath
+func isModuleRepository(modulePath string) bool {
+	if modulePath == "" {
+		return false
+	}
+	out, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return false
+	}
+	data, err := os.ReadFile(filepath.Join(strings.TrimSpace(string(out)), "go.mod"))
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		var fields = strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`) == modulePath
+		}
+	}
+	return false
+}
+
+// Хранилище, которое считает хеш баров контракта при первом полном чтении,
+// чтобы отпечаток данных прогона не требовал повторного чтения баров
+type fingerprintStorage struct {
+	candleStorage domain.ICandleStorage
+	mu            sync.Mutex
+	hashes        map[string][]byte
+}
+
+func newFingerprintStorage(candleStorage domain.ICandleStorage) *fingerprintStorage {
+	return &fingerprintStorage{
+		candleStorage: candleStorage,
+		hashes:        make(map[string][]byte),
+	}
+}
+
+func (s *fingerprintStorage) Candles(securityCode string) iter.Seq2[domain.Candle, error] {
+	return func(yield func(domain.Candle, error) bool) {
+		var h = sha256.New()
+		var buf []byte
+		for candle, err := range s.candleStorage.Candles(securityCode) {
+			if err != nil {
+				// ошибка чтения контракта тоже входит в хеш
+				fmt.Fprintf(h, "error %v\n", err)
+				s.store(securityCode, h.Sum(nil))
+				yield(candle, err)
+				return
+			}
+			buf = strconv.AppendInt(buf[:0], candle.DateTime.Unix(), 10)
+			for _, v := range []float64{candle.OpenPrice, candle.HighPrice, candle.LowPrice, candle.ClosePrice, candle.Volume} {
+				buf = append(buf, ' ')
+				buf = strconv.AppendFloat(buf, v, 'g', -1, 64)
+			}
+			buf = append(buf, '\n')
+			h.Write(buf)
+			if !yield(candle, nil) {
+				// неполное чтение не считается
+				return
+			}
+		}
+		s.store(securityCode, h.Sum(nil))
+	}
+}
+
+func (s *fingerprintStorage) store(securityCode string, hash []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, found := s.hashes[securityCode]; !found {
+		s.hashes[securityCode] = hash
+	}
+}
+
+// Хеш баров контрактов. Контракты, которые не были прочитаны полностью, входят в хеш без баров.
+func (s *fingerprintStorage) Fingerprint(secCodes []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var h = sha256.New()
+	for _, securityCode := range secCodes {
+		fmt.Fprintf(h, "%v %x\n", securityCode, s.hashes[securityCode])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// Прогоны хранилища по возрастанию ID. Каталоги без run.json пропускаются.
+func ListRuns(dir string) ([]RunInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var result []RunInfo
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		info, err := loadRunInfo(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		result = append(result, info)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+func loadRunInfo(runDir string) (RunInfo, error) {
+	data, err := os.ReadFile(filepath.Join(runDir, runInfoFileName))
+	if err != nil {
+		return RunInfo{}, err
+	}
+	var info RunInfo
+	err = json.Unmarshal(data, &info)
+	if err != nil {
+		return RunInfo{}, fmt.Errorf("%v: %w", runDir, err)
+	}
+	return info, nil
+}
+
+// Загружает прогон по ID или однозначному началу ID
+func LoadRun(dir, id string) (Run, error) {
+	runs, err := ListRuns(dir)
+	if err != nil {
+		return Run{}, err
+	}
+	var found []RunInfo
+	for _, info := range runs {
+		if info.ID == id {
+			found = []RunInfo{info}
+			break
+		}
+		if strings.HasPrefix(info.ID, id) {
+			found = append(found, info)
+		}
+	}
+	if len(found) == 0 {
+		return Run{}, fmt.Errorf("run %v not found", id)
+	}
+	if len(found) > 1 {
+		return Run{}, fmt.Errorf("run %v is ambiguous", id)
+	}
+	hprs, err := readRunHprs(filepath.Join(dir, found[0].ID, runHprsFileName))
+	if err != nil {
+		return Run{}, err
+	}
+	return Run{RunInfo: found[0], Hprs: hprs}, nil
+}
+
+func PrintRuns(w io.Writer, runs []RunInfo) {
+	var rows = make([][]string, len(runs))
+	for i, info := range runs {
+		rows[i] = []string{
+			info.ID,
+			info.AdvisorName,
+			info.AdvisorParams.String(),
+			info.SecurityName,
+			fmt.Sprintf("%.1f", info.Lever),
+			formatHprPercent(info.MonthHpr),
+			formatHprPercent(info.MaxDrawdown),
+			formatRatio(info.Sharpe),
+			shortRevision(info.Revision),
+		}
+	}
+	printReportTable(w, reportTable{
+		Header: []string{"ID", "Советник", "Параметры", "Инструмент", "Плечо", "В месяц", "Просадка", "Шарп", "Ревизия"},
+		Rows:   rows,
+	})
+}
+
+func shortRevision(revision string) string {
+	var dirty = strings.HasSuffix(revision, "-dirty")
+	revision = strings.TrimSuffix(revision, "-dirty")
+	if len(revision) > 8 {
+		revision = revision[:8]
+	}
+	if dirty {
+		revision += "+"
+	}
+	return revision
+}
+
+// Сколько дней с наибольшей разницей доходностей выводить
+const runDiffDays = 10
+
+// Сравнивает два прогона: параметры, итоги, доходности по годам и дни с разной доходностью
+func PrintRunDiff(w io.Writer, a, b Run) {
+	var sameData = "различаются"
+	if a.DataFingerprint == b.DataFingerprint {
+		sameData = "совпадают"
+	}
+	printReportTable(w, reportTable{
+		Header: []string{"", a.ID, b.ID},
+		Rows: [][]string{
+			{"Время", a.Time.Format("02.01.2006 15:04"), b.Time.Format("02.01.2006 15:04")},
+			{"Советник", a.AdvisorName, b.AdvisorName},
+			{"Параметры", a.AdvisorParams.String(), b.AdvisorParams.String()},
+			{"Инструмент", a.SecurityName, b.SecurityName},
+			{"Ревизия", shortRevision(a.Revision), shortRevision(b.Revision)},
+			{"Данные (" + sameData + ")", a.DataFingerprint, b.DataFingerprint},
+			{"Плечо", fmt.Sprintf("%.2f", a.Lever), fmt.Sprintf("%.2f", b.Lever)},
+			{"Дней", fmt.Sprint(a.Days), fmt.Sprint(b.Days)},
+			{"Доходность в месяц", formatHprPercent(a.MonthHpr), formatHprPercent(b.MonthHpr)},
+			{"Годовая доходность", formatHprPercent(a.AnnualHpr), formatHprPercent(b.AnnualHpr)},
+			{"Максимальная просадка", formatHprPercent(a.MaxDrawdown), formatHprPercent(b.MaxDrawdown)},
+			{"Коэффициент Шарпа", formatRatio(a.Sharpe), formatRatio(b.Sharpe)},
+		},
+	})
+
+	var yearsA = hprsByPeriod(a.Hprs, firstDayOfYear)
+	var yearsB = hprsByPeriod(b.Hprs, firstDayOfYear)
+	var years = make(map[int][2]float64)
+	for _, item := range yearsA {
+		var v = years[item.Date.Year()]
+		v[0] = item.Sum
+		years[item.Date.Year()] = v
+	}
+	for _, item := range yearsB {
+		var v = years[item.Date.Year()]
+		v[1] = item.Sum
+		years[item.Date.Year()] = v
+	}
+	var keys = make([]int, 0, len(years))
+	for year := range years {
+		keys = append(keys, year)
+	}
+	sort.Ints(keys)
+	var yearRows [][]string
+	for _, year := range keys {
+		var v = years[year]
+		var row = []string{fmt.Sprint(year), formatRunHpr(v[0]), formatRunHpr(v[1]), ""}
+		if v[0] != 0 && v[1] != 0 {
+			row[3] = fmt.Sprintf("%.1f%%", hprPercent(v[1])-hprPercent(v[0]))
+		}
+		yearRows = append(yearRows, row)
+	}
+	printReportTable(w, reportTable{
+		Title:  "Доходности по годам",
+		Header: []string{"Год", a.ID, b.ID, "Разница"},
+		Rows:   yearRows,
+	})
+
+	var diffs = dayDiffs(a.Hprs, b.Hprs)
+	fmt.Fprintf(w, "Дней с разной доходностью: %v\n", len(diffs))
+	sort.SliceStable(diffs, func(i, j int) bool {
+		return diffs[i].size() > diffs[j].size()
+	})
+	var dayRows [][]string
+	for _, d := range diffs[:min(len(diffs), runDiffDays)] {
+		dayRows = append(dayRows, []string{
+			d.date.Format(dateFormatLayout), formatRunHpr(d.a), formatRunHpr(d.b),
+		})
+	}
+	if len(dayRows) != 0 {
+		printReportTable(w, reportTable{
+			Title:  "Дни с наибольшей разницей",
+			Header: []string{"Дата", a.ID, b.ID},
+			Rows:   dayRows,
+		})
+	}
+}
+
+type dayDiff struct {
+	date time.Time
+	// 0, если дня нет в прогоне
+	a, b float64
+}
+
+// Разница доходностей, отсутствующий день считается днем без изменения эквити
+func (d dayDiff) size() float64 {
+	var a, b = d.a, d.b
+	if a == 0 {
+		a = 1
+	}
+	if b == 0 {
+		b = 1
+	}
+	return math.Abs(b - a)
+}
+
+// Дни, которые есть только в одном прогоне или доходности которых различаются
+func dayDiffs(a, b []DateSum) []dayDiff {
+	const epsilon = 1e-9
+	var result []dayDiff
+	var i, j = 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case j == len(b) || i < len(a) && a[i].Date.Before(b[j].Date):
+			result = append(result, dayDiff{date: a[i].Date, a: a[i].Sum})
+			i++
+		case i == len(a) || b[j].Date.Before(a[i].Date):
+			result = append(result, dayDiff{date: b[j].Date, b: b[j].Sum})
+			j++
+		default:
+			if math.Abs(a[i].Sum-b[j].Sum) > epsilon {
+				result = append(result, dayDiff{date: a[i].Date, a: a[i].Sum, b: b[j].Sum})
+			}
+			i++
+			j++
+		}
+	}
+	return result
+}
+
+func formatRunHpr(hpr float64) string {
+	if hpr == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f%%", hprPercent(hpr))
+}
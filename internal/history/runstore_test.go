@@ -0,0 +1,87 @@
+package history
+
+import (
+	"advisordev/internal/domain"
+	"iter"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunHprs(t *testing.T) {
+	var date = func(day int) time.Time {
+		return time.Date(2025, time.March, day, 0, 0, 0, 0, time.UTC)
+	}
+	var hprs = []DateSum{{date(3), 1.0123456789}, {date(4), 0.97}, {date(5), 1}}
+	var path = filepath.Join(t.TempDir(), runHprsFileName)
+	var err = writeRunHprs(path, hprs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := readRunHprs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != len(hprs) {
+		t.Fatalf("loaded %+v", loaded)
+	}
+	for i := range hprs {
+		if !loaded[i].Date.Equal(hprs[i].Date) || loaded[i].Sum != hprs[i].Sum {
+			t.Errorf("loaded %+v, want %+v", loaded[i], hprs[i])
+		}
+	}
+
+	var other = []DateSum{{date(4), 0.98}, {date(5), 1}, {date(6), 1.01}}
+	var diffs = dayDiffs(hprs, other)
+	var expected = []dayDiff{
+		{date: date(3), a: 1.0123456789},
+		{date: date(4), a: 0.97, b: 0.98},
+		{date: date(6), b: 1.01},
+	}
+	if len(diffs) != len(expected) {
+		t.Fatalf("diffs %+v", diffs)
+	}
+	for i := range expected {
+		if diffs[i] != expected[i] {
+			t.Errorf("diff %+v, want %+v", diffs[i], expected[i])
+		}
+	}
+}
+
+type testCandleStorage map[string][]float64
+
+func (s testCandleStorage) Candles(securityCode string) iter.Seq2[domain.Candle, error] {
+	return testCandles(s[securityCode]...)
+}
+
+func TestFingerprintStorage(t *testing.T) {
+	var secCodes = []string{"Si-3.24", "Si-6.24"}
+	var fingerprint = func(storage testCandleStorage, read func(*fingerprintStorage)) string {
+		var fingerprints = newFingerprintStorage(storage)
+		read(fingerprints)
+		return fingerprints.Fingerprint(secCodes)
+	}
+	var readAll = func(fingerprints *fingerprintStorage) {
+		for _, securityCode := range secCodes {
+			for range fingerprints.Candles(securityCode) {
+			}
+		}
+	}
+	var storage = testCandleStorage{"Si-3.24": {100, 101}, "Si-6.24": {102}}
+	var expected = fingerprint(storage, readAll)
+	if fingerprint(storage, readAll) != expected {
+		t.Error("fingerprint is not stable")
+	}
+	if fingerprint(testCandleStorage{"Si-3.24": {100, 101}, "Si-6.24": {103}}, readAll) == expected {
+		t.Error("fingerprint does not depend on bars")
+	}
+	// неполное чтение не считается
+	var partial = fingerprint(storage, func(fingerprints *fingerprintStorage) {
+		for range fingerprints.Candles("Si-3.24") {
+			break
+		}
+	})
+	if partial == expected {
+		t.Error("partial read counted")
+	}
+}
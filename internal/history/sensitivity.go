@@ -0,0 +1,234 @@
+package history
+
+import (
+	"advisordev/internal/domain"
+	"advisordev/internal/moex"
+	"fmt"
+	"math"
+	"runtime"
+	"time"
+)
+
+// Перебор издержек. Если оба списка пусты, то перебора нет.
+type SensitivitySettings struct {
+	// Постоянные издержки (доли цены) вместо модели издержек отчета
+	Slippages []float64
+	// Множители модели издержек отчета (тарифы или -slippage)
+	CostMultipliers []float64
+}
+
+// Итоги прогона при издержках Cost (доля цены или множитель)
+type CostPoint struct {
+	Cost float64
+	// Доходность в месяц без плеча
+	BaseMonthHpr float64
+	// Плечо (подобранное по правилу отчета или заданное) и доходность в месяц и просадка с ним
+	Lever       float64
+	MonthHpr    float64
+	MaxDrawdown float64
+}
+
+type CostSweep struct {
+	Points []CostPoint
+	// Издержки, при которых итоговая доходность без плеча равна 0.
+	// 0, если советник убыточен и без издержек или не торгует.
+	BreakEven float64
+}
+
+type SensitivityResult struct {
+	Slippage   *CostSweep `json:",omitempty"`
+	Multiplier *CostSweep `json:",omitempty"`
+}
+
+func costSensitivity(
+	candleStorage domain.ICandleStorage,
+	newAdvisor func() domain.Advisor,
+	secCodes []string,
+	settings ReportSettings,
+) (SensitivityResult, error) {
+	var result SensitivityResult
+	var hprsWithCost = func(cost CostModel) ([]DateSum, error) {
+		contracts, err := multiContract(
+			candleStorage, newAdvisor, secCodes, cost, settings.Roll, settings.Strict,
+			moex.FortsCalendar.IsAfterHolidays, runtime.NumCPU())
+		if err != nil {
+			return nil, err
+		}
+		if len(contracts.Hprs) == 0 {
+			return nil, fmt.Errorf("no data %v", settings.SecurityName)
+		}
+		return contracts.Hprs, nil
+	}
+	if len(settings.Sensitivity.Slippages) != 0 {
+		sweep, err := costSweep(settings.Sensitivity.Slippages, settings, func(slippage float64) ([]DateSum, error) {
+			return hprsWithCost(ConstantCost(slippage))
+		})
+		if err != nil {
+			return SensitivityResult{}, err
+		}
+		result.Slippage = &sweep
+	}
+	if len(settings.Sensitivity.CostMultipliers) != 0 {
+		sweep, err := costSweep(settings.Sensitivity.CostMultipliers, settings, func(multiplier float64) ([]DateSum, error) {
+			return hprsWithCost(scaledCost(settings.Cost, multiplier))
+		})
+		if err != nil {
+			return SensitivityResult{}, err
+		}
+		result.Multiplier = &sweep
+	}
+	return result, nil
+}
+
+func scaledCost(cost CostModel, multiplier float64) CostModel {
+	return func(securityCode string, dateTime time.Time, price float64) (float64, error) {
+		c, err := cost(securityCode, dateTime, price)
+		return c * multiplier, err
+	}
+}
+
+func costSweep(
+	costs []float64,
+	settings ReportSettings,
+	hprsWithCost func(float64) ([]DateSum, error),
+) (CostSweep, error) {
+	var result CostSweep
+	var hprsByCosts = make([][]DateSum, len(costs))
+	for i, cost := range costs {
+		hprs, err := hprsWithCost(cost)
+		if err != nil {
+			return CostSweep{}, err
+		}
+		hprsByCosts[i] = hprs
+		var point = CostPoint{
+			Cost:         cost,
+			BaseMonthHpr: monthHpr(hprs),
+			Lever:        settings.Lever,
+		}
+		if point.Lever == 0 {
+			sizing, err := selectLever(hprs, settings.Sizing)
+			if err != nil {
+				return CostSweep{}, err
+			}
+			point.Lever = sizing.Lever
+		}
+		var leverHprs = hprsWithLever(hprs, point.Lever)
+		point.MonthHpr = monthHpr(leverHprs)
+		point.MaxDrawdown = computeDrawdownInfo(leverHprs).MaxDrawdown
+		result.Points = append(result.Points, point)
+	}
+
+	// две точки с разными издержками, при необходимости досчитываем издержки 0
+	var low, high = 0, 0
+	for i, cost := range costs {
+		if cost < costs[low] {
+			low = i
+		}
+		if cost > costs[high] {
+			high = i
+		}
+	}
+	var lowCost, lowHprs = costs[low], hprsByCosts[low]
+	var highCost, highHprs = costs[high], hprsByCosts[high]
+	if lowCost == highCost {
+		if highCost == 0 {
+			return result, nil
+		}
+		hprs, err := hprsWithCost(0)
+		if err != nil {
+			return CostSweep{}, err
+		}
+		lowCost, lowHprs = 0, hprs
+	}
+	result.BreakEven = breakEvenCost(lowCost, lowHprs, highCost, highHprs)
+	return result, nil
+}
+
+func monthHpr(hprs []DateSum) float64 {
+	return math.Pow(totalHpr(hprs), 22.0/float64(len(hprs)))
+}
+
+// Издержки входят в дневную доходность линейно (позиции советника от издержек не зависят),
+// поэтому доходности при любых издержках восстанавливаются по двум прогонам.
+// Ищет половинным делением издержки, при которых итоговая доходность равна 1.
+func breakEvenCost(lowCost float64, lowHprs []DateSum, highCost float64, highHprs []DateSum) float64 {
+	if len(lowHprs) != len(highHprs) {
+		return 0
+	}
+	var hprsAt = func(cost float64) []DateSum {
+		var k = (cost - lowCost) / (highCost - lowCost)
+		var result = make([]DateSum, len(lowHprs))
+		for i := range lowHprs {
+			result[i] = DateSum{
+				Date: lowHprs[i].Date,
+				Sum:  lowHprs[i].Sum + k*(highHprs[i].Sum-lowHprs[i].Sum),
+			}
+		}
+		return result
+	}
+	var profitable = func(cost float64) bool {
+		for _, item := range hprsAt(cost) {
+			if item.Sum <= 0 {
+				return false
+			}
+		}
+		return totalHpr(hprsAt(cost)) > 1
+	}
+	if !profitable(0) {
+		return 0
+	}
+	// издержки растут, пока советник прибылен (если сделок нет, то доходности не меняются)
+	var hi = math.Max(highCost, 1e-6)
+	for profitable(hi) {
+		hi *= 2
+		if hi > 1e6 {
+			return 0
+		}
+	}
+	var lo = 0.0
+	for i := 0; i < 60; i++ {
+		var mid = (lo + hi) / 2
+		if profitable(mid) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+func costSweepTable(title string, sweep CostSweep, formatCost func(float64) string) reportTable {
+	var rows [][]string
+	for _, p := range sweep.Points {
+		rows = append(rows, []string{
+			formatCost(p.Cost),
+			formatHprPercent(p.BaseMonthHpr),
+			fmt.Sprintf("%.1f", p.Lever),
+			formatHprPercent(p.MonthHpr),
+			formatHprPercent(p.MaxDrawdown),
+		})
+	}
+	var breakEven = "нет"
+	if sweep.BreakEven != 0 {
+		breakEven = formatCost(sweep.BreakEven)
+	}
+	rows = append(rows, []string{"Безубыточность", breakEven, "", "", ""})
+	return reportTable{
+		Title:  title,
+		Header: []string{"Издержки", "В месяц без плеча", "Плечо", "В месяц", "Макс. просадка"},
+		Rows:   rows,
+	}
+}
+
+func sensitivityTables(sensitivity SensitivityResult) []reportTable {
+	var result []reportTable
+	if sensitivity.Slippage != nil {
+		result = append(result, costSweepTable("Чувствительность к проскальзыванию", *sensitivity.Slippage,
+			func(x float64) string { return fmt.Sprintf("%.3f%%", x*100) }))
+	}
+	if sensitivity.Multiplier != nil {
+		result = append(result, costSweepTable("Чувствительность к множителю издержек", *sensitivity.Multiplier,
+			func(x float64) string { return fmt.Sprintf("x%.2f", x) }))
+	}
+	return result
+}
@@ -0,0 +1,39 @@
+package history
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBreakEvenCost(t *testing.T) {
+	var start = time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC)
+	// доходность дня 1.01 без издержек, издержки 2 оборота в день
+	var hprsAt = func(cost float64) []DateSum {
+		var result []DateSum
+		for i := 0; i < 20; i++ {
+			result = append(result, DateSum{Date: start.AddDate(0, 0, i), Sum: 1.01 - 2*cost})
+		}
+		return result
+	}
+	var tests = []struct {
+		lowCost, highCost float64
+		expected          float64
+	}{
+		{0, 0.001, 0.005},
+		{0.0002, 0.0004, 0.005},
+		// точки дальше безубыточности
+		{0.006, 0.01, 0.005},
+	}
+	for _, test := range tests {
+		var result = breakEvenCost(test.lowCost, hprsAt(test.lowCost), test.highCost, hprsAt(test.highCost))
+		if math.Abs(result-test.expected) > 1e-9 {
+			t.Errorf("%+v: break even %v", test, result)
+		}
+	}
+
+	// без сделок доходности от издержек не зависят
+	if result := breakEvenCost(0, hprsAt(0), 0.001, hprsAt(0)); result != 0 {
+		t.Errorf("no trades: break even %v", result)
+	}
+}
@@ -0,0 +1,211 @@
+package history
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+)
+
+const (
+	// Дневное стандартное отклонение не больше Target (прежнее правило, по умолчанию 0.045)
+	SizingStDev = "stdev"
+	// Доля Target от плеча с максимальной итоговой доходностью (дробный Келли)
+	SizingKelly = "kelly"
+	// Годовая волатильность не больше Target
+	SizingVolatility = "volatility"
+	// Максимальная просадка не больше Target
+	SizingDrawdown = "drawdown"
+	// Средняя доходность худших дней (CVaR уровня Level) не хуже -Target
+	SizingCVaR = "cvar"
+)
+
+// Правило подбора плеча, если оно не задано явно
+type SizingSettings struct {
+	// SizingStDev (по умолчанию), SizingKelly, SizingVolatility, SizingDrawdown или SizingCVaR
+	Method string
+	// Если 0, то значение по умолчанию для метода
+	Target float64
+	// Уровень CVaR для SizingCVaR
+	Level float64
+}
+
+var defaultSizingTargets = map[string]float64{
+	SizingStDev:      defaultStDevLimit,
+	SizingKelly:      0.5,
+	SizingVolatility: 0.5,
+	SizingDrawdown:   0.3,
+	SizingCVaR:       0.05,
+}
+
+const defaultSizingLevel = 0.05
+
+// Число точек кривой плеча
+const leverCurvePoints = 20
+
+func (s SizingSettings) Validate() error {
+	var method = s.Method
+	if method == "" {
+		method = SizingStDev
+	}
+	if _, found := defaultSizingTargets[method]; !found {
+		return fmt.Errorf("bad sizing method %q", s.Method)
+	}
+	if s.Target < 0 || (method == SizingDrawdown || method == SizingCVaR) && s.Target >= 1 {
+		return fmt.Errorf("bad sizing target %v", s.Target)
+	}
+	if s.Level < 0 || s.Level >= 1 {
+		return fmt.Errorf("bad sizing level %v", s.Level)
+	}
+	return nil
+}
+
+// Точка кривой: доходность в месяц и максимальная просадка при плече Lever
+type LeverPoint struct {
+	Lever       float64
+	MonthHpr    float64
+	MaxDrawdown float64
+}
+
+// Подобранное плечо и зависимость доходности и просадки от плеча
+type SizingResult struct {
+	Method string
+	Target float64
+	Level  float64 `json:",omitempty"`
+	Lever  float64
+	// Плечо с максимальной итоговой доходностью без ограничений
+	OptimalLever float64
+	Curve        []LeverPoint
+}
+
+// Подбирает плечо по правилу settings для доходностей без плеча
+func selectLever(hprs []DateSum, settings SizingSettings) (SizingResult, error) {
+	var err = settings.Validate()
+	if err != nil {
+		return SizingResult{}, err
+	}
+	if settings.Method == "" {
+		settings.Method = SizingStDev
+	}
+	if settings.Target == 0 {
+		settings.Target = defaultSizingTargets[settings.Method]
+	}
+	if settings.Method == SizingCVaR && settings.Level == 0 {
+		settings.Level = defaultSizingLevel
+	}
+
+	var result = SizingResult{
+		Method:       settings.Method,
+		Target:       settings.Target,
+		OptimalLever: optimalLever(hprs, func([]DateSum) bool { return true }),
+	}
+	switch settings.Method {
+	case SizingStDev:
+		result.Lever = optimalLever(hprs, limitStDev(settings.Target))
+	case SizingKelly:
+		result.Lever = settings.Target * result.OptimalLever
+	case SizingVolatility:
+		result.Lever = optimalLever(hprs, limitVolatility(settings.Target))
+	case SizingDrawdown:
+		result.Lever = optimalLever(hprs, limitDrawdown(settings.Target))
+	case SizingCVaR:
+		result.Level = settings.Level
+		result.Lever = optimalLever(hprs, limitCVaR(settings.Level, settings.Target))
+	}
+	result.Curve = leverCurve(hprs, result.Lever, math.Max(result.Lever, result.OptimalLever))
+	return result, nil
+}
+
+// Ограничение на годовую волатильность логарифмов дневных доходностей
+func limitVolatility(volatility float64) func([]DateSum) bool {
+	return func(source []DateSum) bool {
+		return stDevHprs(source)*math.Sqrt(tradingDaysPerYear(source)) <= volatility
+	}
+}
+
+// Ограничение на максимальную просадку (доля от максимума эквити)
+func limitDrawdown(drawdown float64) func([]DateSum) bool {
+	return func(source []DateSum) bool {
+		return computeDrawdownInfo(source).MaxDrawdown >= 1-drawdown
+	}
+}
+
+// Ограничение на среднюю доходность худших level дней
+func limitCVaR(level, loss float64) func([]DateSum) bool {
+	return func(source []DateSum) bool {
+		var sortedHprs = make([]DateSum, len(source))
+		copy(sortedHprs, source)
+		sort.Slice(sortedHprs, func(i, j int) bool {
+			return sortedHprs[i].Sum < sortedHprs[j].Sum
+		})
+		return computeCVaR(sortedHprs, []float64{level})[0].Hpr >= 1-loss
+	}
+}
+
+// Кривая плеча от 0 до удвоенного maxLever (но не больше плеча, при котором худший день обнуляет счет)
+// с точкой выбранного плеча lever
+func leverCurve(hprs []DateSum, lever, maxLever float64) []LeverPoint {
+	var minHpr = hprs[0].Sum
+	for _, x := range hprs[1:] {
+		minHpr = math.Min(minHpr, x.Sum)
+	}
+	var upper = 2 * maxLever
+	if minHpr < 1 {
+		upper = math.Min(upper, 1/(1-minHpr))
+	}
+	if upper <= 0 {
+		return nil
+	}
+	var levers []float64
+	for i := 1; i <= leverCurvePoints; i++ {
+		levers = append(levers, upper*float64(i)/leverCurvePoints)
+	}
+	if lever > 0 && !slices.Contains(levers, lever) {
+		levers = append(levers, lever)
+		slices.Sort(levers)
+	}
+	var result = make([]LeverPoint, len(levers))
+	for i, l := range levers {
+		var leverHprs = hprsWithLever(hprs, l)
+		result[i] = LeverPoint{
+			Lever:       l,
+			MonthHpr:    math.Pow(totalHpr(leverHprs), 22.0/float64(len(leverHprs))),
+			MaxDrawdown: computeDrawdownInfo(leverHprs).MaxDrawdown,
+		}
+	}
+	return result
+}
+
+var sizingMethodNames = map[string]string{
+	SizingStDev:      "дневное ст. отклонение",
+	SizingKelly:      "доля Келли",
+	SizingVolatility: "годовая волатильность",
+	SizingDrawdown:   "макс. просадка",
+	SizingCVaR:       "CVaR",
+}
+
+func sizingTable(sizing SizingResult) reportTable {
+	var rows = make([][]string, len(sizing.Curve))
+	for i, p := range sizing.Curve {
+		var mark = ""
+		if p.Lever == sizing.Lever {
+			mark = "*"
+		}
+		rows[i] = []string{
+			fmt.Sprintf("%.2f", p.Lever),
+			fmt.Sprintf("%.1f%%", hprPercent(p.MonthHpr)),
+			fmt.Sprintf("%.1f%%", hprPercent(p.MaxDrawdown)),
+			mark,
+		}
+	}
+	var title = fmt.Sprintf("Подбор плеча: %v %v", sizingMethodNames[sizing.Method], sizing.Target)
+	if sizing.Method == SizingCVaR {
+		title += fmt.Sprintf(" (уровень %v)", sizing.Level)
+	}
+	title += fmt.Sprintf(", плечо без ограничений %.2f", sizing.OptimalLever)
+	return reportTable{
+		Title:  title,
+		Header: []string{"Плечо", "Доходность в месяц", "Макс. просадка", "Выбрано"},
+		Rows:   rows,
+	}
+}
@@ -0,0 +1,68 @@
+package history
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSelectLever(t *testing.T) {
+	var start = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	var hprs []DateSum
+	for i := 0; i < 500; i++ {
+		var sum = 1.02
+		if i%2 == 1 {
+			sum = 0.985
+		}
+		if i%50 == 10 {
+			sum = 0.96
+		}
+		hprs = append(hprs, DateSum{Date: start.AddDate(0, 0, i), Sum: sum})
+	}
+	var tests = []struct {
+		settings SizingSettings
+		risk     func([]DateSum) bool
+	}{
+		{SizingSettings{}, limitStDev(defaultStDevLimit)},
+		{SizingSettings{Method: SizingVolatility, Target: 0.3}, limitVolatility(0.3)},
+		{SizingSettings{Method: SizingDrawdown, Target: 0.2}, limitDrawdown(0.2)},
+		{SizingSettings{Method: SizingCVaR, Target: 0.03, Level: 0.05}, limitCVaR(0.05, 0.03)},
+		{SizingSettings{Method: SizingKelly, Target: 0.5}, nil},
+	}
+	for _, test := range tests {
+		result, err := selectLever(hprs, test.settings)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !(result.Lever > 0 && result.Lever <= result.OptimalLever) {
+			t.Errorf("%+v: lever %v, optimal %v", test.settings, result.Lever, result.OptimalLever)
+			continue
+		}
+		if test.risk != nil && !test.risk(hprsWithLever(hprs, result.Lever)) {
+			t.Errorf("%+v: lever %v breaks limit", test.settings, result.Lever)
+		}
+		if test.settings.Method == SizingKelly && math.Abs(result.Lever-0.5*result.OptimalLever) > 1e-9 {
+			t.Errorf("kelly lever %v, optimal %v", result.Lever, result.OptimalLever)
+		}
+		var found = false
+		for i, p := range result.Curve {
+			found = found || p.Lever == result.Lever
+			if i > 0 && !(p.Lever > result.Curve[i-1].Lever && p.MaxDrawdown <= result.Curve[i-1].MaxDrawdown) {
+				t.Errorf("%+v: bad curve point %+v", test.settings, p)
+			}
+		}
+		if !found {
+			t.Errorf("%+v: lever %v not in curve", test.settings, result.Lever)
+		}
+	}
+
+	for _, settings := range []SizingSettings{
+		{Method: "bad"},
+		{Method: SizingDrawdown, Target: 1.5},
+		{Method: SizingCVaR, Level: 1},
+	} {
+		if _, err := selectLever(hprs, settings); err == nil {
+			t.Errorf("%+v: expected error", settings)
+		}
+	}
+}
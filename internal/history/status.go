@@ -0,0 +1,259 @@
+package history
+
+import (
+	advisors "advisordev/internal/advisors_sample"
+	"advisordev/internal/domain"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type StatusSettings struct {
+	AdvisorName   string
+	AdvisorParams advisors.Params
+	SecurityName  string
+	// Период изменений позиции по датам включительно. Нулевая дата - без ограничения.
+	Start  time.Time
+	Finish time.Time
+	// Сколько последних изменений показать. Если 0, то все за период.
+	Last int
+	// Файл для выгрузки изменений позиции (csv или json)
+	OutputPath string
+}
+
+// Изменение позиции советника
+type PositionChange struct {
+	SecurityCode string
+	DateTime     time.Time
+	Price        float64
+	OldPosition  float64
+	NewPosition  float64
+	// Доходность новой позиции от цены изменения до следующего изменения или до последней цены
+	Pnl float64
+	// Позиция держится до последнего бара, Pnl нереализованный
+	Open    bool
+	Details any
+}
+
+func AdvisorStatus(
+	candleStorage domain.ICandleStorage,
+	settings StatusSettings,
+) error {
+	newAdvisor, err := advisors.TestAdvisor(settings.AdvisorName, settings.AdvisorParams)
+	if err != nil {
+		return err
+	}
+	changes, err := positionChanges(candleStorage.Candles(settings.SecurityName), newAdvisor())
+	if err != nil {
+		return err
+	}
+	changes = filterPositionChanges(changes, settings.Start, settings.Finish)
+	if settings.Last > 0 && len(changes) > settings.Last {
+		changes = changes[len(changes)-settings.Last:]
+	}
+	printPositionChanges(os.Stdout, changes)
+	if settings.OutputPath != "" {
+		err = WritePositionChanges(settings.OutputPath, changes)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func positionChanges(candles iter.Seq2[domain.Candle, error], advisor domain.Advisor) ([]PositionChange, error) {
+	var result []PositionChange
+	var lastPrice = 0.0
+	for candle, err := range candles {
+		if err != nil {
+			return nil, err
+		}
+		lastPrice = candle.ClosePrice
+		var advice = advisor(candle)
+		if advice.DateTime.IsZero() {
+			continue
+		}
+		var oldPosition = 0.0
+		if len(result) != 0 {
+			oldPosition = result[len(result)-1].NewPosition
+			if oldPosition == advice.Position {
+				continue
+			}
+		}
+		result = append(result, PositionChange{
+			SecurityCode: advice.SecurityCode,
+			DateTime:     advice.DateTime,
+			Price:        advice.Price,
+			OldPosition:  oldPosition,
+			NewPosition:  advice.Position,
+			Details:      advice.Details,
+		})
+	}
+	for i := range result {
+		var exitPrice = lastPrice
+		if i+1 < len(result) {
+			exitPrice = result[i+1].Price
+		} else {
+			result[i].Open = true
+		}
+		if result[i].Price != 0 {
+			result[i].Pnl = result[i].NewPosition * (exitPrice/result[i].Price - 1)
+		}
+	}
+	return result, nil
+}
+
+func filterPositionChanges(changes []PositionChange, start, finish time.Time) []PositionChange {
+	var result []PositionChange
+	for _, change := range changes {
+		var date = dateTimeToDate(change.DateTime)
+		if !start.IsZero() && date.Before(sameDate(start, date.Location())) {
+			continue
+		}
+		if !finish.IsZero() && date.After(sameDate(finish, date.Location())) {
+			continue
+		}
+		result = append(result, change)
+	}
+	return result
+}
+
+// Дата флага (UTC) в часовом поясе баров
+func sameDate(d time.Time, loc *time.Location) time.Time {
+	var y, m, day = d.Date()
+	return time.Date(y, m, day, 0, 0, 0, 0, loc)
+}
+
+func printPositionChanges(w io.Writer, changes []PositionChange) {
+	var open = false
+	var tw = newTabWriter(w)
+	fmt.Fprintf(tw, "Время\tЦена\tБыло\tСтало\tДоходность\t\n")
+	for _, change := range changes {
+		var pnl = fmt.Sprintf("%.2f%%", change.Pnl*100)
+		if change.Open {
+			pnl += " *"
+			open = true
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%.2f\t%.2f\t%v\t\n",
+			change.DateTime.Format(time.DateTime), strconv.FormatFloat(change.Price, 'f', -1, 64),
+			change.OldPosition, change.NewPosition, pnl)
+	}
+	tw.Flush()
+	if open {
+		fmt.Fprintln(w, "* позиция открыта, доходность нереализованная")
+	}
+
+	for _, change := range changes {
+		var lines = detailsTree(change.Details)
+		if len(lines) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%v\n", change.DateTime.Format(time.DateTime))
+		for _, line := range lines {
+			fmt.Fprintln(w, "  "+line)
+		}
+	}
+}
+
+// Цепочка Details (декоратор -> советник) в виде дерева: у каждого узла имя (поле Name)
+// и простые поля, вложенные структуры - дочерние узлы с отступом.
+func detailsTree(details any) []string {
+	var result []string
+	appendDetailsNode(&result, reflect.ValueOf(details), 0)
+	return result
+}
+
+func appendDetailsNode(lines *[]string, v reflect.Value, depth int) {
+	for v.IsValid() && (v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer) {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return
+	}
+	var name string
+	var fields []string
+	var children []reflect.Value
+	var add = func(key string, value reflect.Value) {
+		for value.Kind() == reflect.Interface || value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				return
+			}
+			value = value.Elem()
+		}
+		switch value.Kind() {
+		case reflect.Struct, reflect.Map:
+			children = append(children, value)
+		default:
+			if key == "Name" {
+				name = fmt.Sprint(value.Interface())
+			} else {
+				fields = append(fields, key+"="+formatDetailsValue(value))
+			}
+		}
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				add(v.Type().Field(i).Name, v.Field(i))
+			}
+		}
+	case reflect.Map:
+		// детали из JSON лога: ключи сортируем для стабильного вывода
+		var keys = v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, key := range keys {
+			add(fmt.Sprint(key.Interface()), v.MapIndex(key))
+		}
+	default:
+		*lines = append(*lines, strings.Repeat("  ", depth)+formatDetailsValue(v))
+		return
+	}
+	var line = strings.TrimSpace(name + " " + strings.Join(fields, " "))
+	*lines = append(*lines, strings.Repeat("  ", depth)+line)
+	for _, child := range children {
+		appendDetailsNode(lines, child, depth+1)
+	}
+}
+
+func formatDetailsValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', 4, 64)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// Формат определяется по расширению (см. writeJSONOrCSV)
+func WritePositionChanges(path string, changes []PositionChange) error {
+	var header = []string{"security", "time", "price", "old_position", "new_position", "pnl", "open", "details"}
+	var rows [][]string
+	for _, change := range changes {
+		var details = detailsTree(change.Details)
+		for i := range details {
+			details[i] = strings.TrimSpace(details[i])
+		}
+		rows = append(rows, []string{
+			change.SecurityCode,
+			change.DateTime.Format(time.DateTime),
+			formatFloat(change.Price),
+			formatFloat(change.OldPosition),
+			formatFloat(change.NewPosition),
+			formatFloat(change.Pnl),
+			strconv.FormatBool(change.Open),
+			strings.Join(details, " > "),
+		})
+	}
+	return writeJSONOrCSV(path, changes, header, rows)
+}
@@ -0,0 +1,81 @@
+package history
+
+import (
+	"advisordev/internal/domain"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPositionChanges(t *testing.T) {
+	var start = time.Date(2025, 4, 1, 10, 0, 0, 0, time.UTC)
+	var prices = []float64{100, 102, 104, 103, 110}
+	var positions = []float64{1, 1, -1, -1, 0.5}
+	var candles = func(yield func(domain.Candle, error) bool) {
+		for i, price := range prices {
+			if !yield(domain.Candle{DateTime: start.Add(time.Duration(i) * time.Minute), ClosePrice: price}, nil) {
+				return
+			}
+		}
+	}
+	var i = 0
+	var advisor = func(candle domain.Candle) domain.Advice {
+		var advice = domain.Advice{DateTime: candle.DateTime, Price: candle.ClosePrice, Position: positions[i]}
+		i++
+		return advice
+	}
+	changes, err := positionChanges(candles, advisor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 3 {
+		t.Fatalf("changes %+v", changes)
+	}
+	var tests = []struct {
+		oldPosition, newPosition, pnl float64
+		open                          bool
+	}{
+		{0, 1, 0.04, false},
+		{1, -1, -0.0576923, false},
+		{-1, 0.5, 0, true},
+	}
+	for i, test := range tests {
+		var change = changes[i]
+		if change.OldPosition != test.oldPosition || change.NewPosition != test.newPosition ||
+			math.Abs(change.Pnl-test.pnl) > 1e-6 || change.Open != test.open {
+			t.Errorf("change %v: %+v", i, change)
+		}
+	}
+}
+
+func TestDetailsTree(t *testing.T) {
+	type Child struct {
+		Name string
+		Fast float64
+	}
+	type Parent struct {
+		Name          string
+		ChildPosition float64
+		ChildDetails  any
+	}
+	var want = []string{
+		"std ChildPosition=0.5000",
+		"  ma Fast=1.2500",
+	}
+	var got = detailsTree(Parent{Name: "std", ChildPosition: 0.5, ChildDetails: Child{Name: "ma", Fast: 1.25}})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("detailsTree struct = %q, want %q", got, want)
+	}
+	// детали из JSON лога
+	got = detailsTree(map[string]any{
+		"Name": "std", "ChildPosition": 0.5,
+		"ChildDetails": map[string]any{"Name": "ma", "Fast": 1.25},
+	})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("detailsTree map = %q, want %q", got, want)
+	}
+	if got = detailsTree(nil); len(got) != 0 {
+		t.Errorf("detailsTree nil = %q", got)
+	}
+}
@@ -0,0 +1,335 @@
+package history
+
+import (
+	"advisordev/internal/domain"
+	"fmt"
+	"iter"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Сделка - интервал, в течение которого позиция советника не меняет знак.
+// Изменение размера позиции без смены знака остается внутри сделки.
+type Trade struct {
+	SecurityCode string
+	EntryTime    time.Time
+	ExitTime     time.Time
+	EntryPrice   float64
+	ExitPrice    float64
+	// Позиция при входе, со знаком
+	Size float64
+	// Доходность сделки относительно цены входа, с учетом проскальзывания
+	Pnl float64
+	// Максимальное неблагоприятное и благоприятное движение цены от входа (доли цены входа)
+	MAE float64
+	MFE float64
+	// Сделка закрыта не советником, а по последней цене контракта или при переходе на следующий контракт
+	Open bool
+	// Состояние на конец каждого дня контракта и на закрытие, чтобы обрезать сделку по дате перехода
+	marks []tradeMark
+}
+
+type tradeMark struct {
+	// Индекс дня контракта
+	day      int
+	time     time.Time
+	price    float64
+	position float64
+	// Доход с начала сделки в пунктах, с учетом проскальзывания
+	pnl float64
+	// Экстремумы цены за день, пока сделка открыта
+	high float64
+	low  float64
+}
+
+func (t Trade) HoldingTime() time.Duration {
+	return t.ExitTime.Sub(t.EntryTime)
+}
+
+// Сделка между отметками base (-1 - от входа) и final. Издержки в пунктах.
+func (t Trade) between(base, final int, openCost, closeCost float64) Trade {
+	var result = t
+	var basePnl = 0.0
+	if base >= 0 {
+		var b = t.marks[base]
+		result.EntryTime = b.time
+		result.EntryPrice = b.price
+		result.Size = b.position
+		basePnl = b.pnl
+	}
+	var f = t.marks[final]
+	result.ExitTime = f.time
+	result.ExitPrice = f.price
+	result.Pnl = (f.pnl - basePnl - openCost - closeCost) / result.EntryPrice
+	result.MAE = 0
+	result.MFE = 0
+	var direction = math.Copysign(1, result.Size)
+	for _, mark := range t.marks[base+1 : final+1] {
+		var high = direction * (mark.high - result.EntryPrice) / result.EntryPrice
+		var low = direction * (mark.low - result.EntryPrice) / result.EntryPrice
+		result.MFE = math.Max(result.MFE, math.Max(high, low))
+		result.MAE = math.Min(result.MAE, math.Min(high, low))
+	}
+	result.marks = nil
+	return result
+}
+
+// Часть сделки в днях контракта [from, to), to < 0 - до конца данных. Сделка, открытая до дня from,
+// входит в конце дня from-1, а не закрытая до дня to закрывается в конце дня to-1, с издержками cost.
+func (t Trade) window(from, to int, cost CostModel) (Trade, bool, error) {
+	var entryDay, exitDay = t.marks[0].day, t.marks[len(t.marks)-1].day
+	if exitDay < from || to >= 0 && entryDay >= to {
+		return Trade{}, false, nil
+	}
+	var base, final = -1, len(t.marks) - 1
+	var openCost, closeCost = 0.0, 0.0
+	var open = t.Open
+	if entryDay < from {
+		base = sort.Search(len(t.marks), func(k int) bool { return t.marks[k].day >= from }) - 1
+		var b = t.marks[base]
+		c, err := cost(t.SecurityCode, b.time, b.price)
+		if err != nil {
+			return Trade{}, false, err
+		}
+		openCost = c * b.price * math.Abs(b.position)
+	}
+	if to >= 0 && exitDay >= to {
+		final = sort.Search(len(t.marks), func(k int) bool { return t.marks[k].day >= to }) - 1
+		var f = t.marks[final]
+		c, err := cost(t.SecurityCode, f.time, f.price)
+		if err != nil {
+			return Trade{}, false, err
+		}
+		closeCost = c * f.price * math.Abs(f.position)
+		open = true
+	}
+	var result = t.between(base, final, openCost, closeCost)
+	result.Open = open
+	return result, true, nil
+}
+
+// Сделки советника по ходу тестирования контракта (см. singleContractDays)
+type tradeTracker struct {
+	trades []Trade
+	trade  *Trade
+	// Доход открытой сделки в пунктах и экстремумы цены за текущий день
+	pnl  float64
+	high float64
+	low  float64
+}
+
+// Первый совет: начальная позиция без проскальзывания, как и в доходностях
+func (tt *tradeTracker) start(advice domain.Advice) {
+	if advice.Position != 0 {
+		tt.open(advice, 0)
+	}
+}
+
+func (tt *tradeTracker) open(advice domain.Advice, pnl float64) {
+	tt.trade = &Trade{
+		SecurityCode: advice.SecurityCode,
+		EntryTime:    advice.DateTime,
+		EntryPrice:   advice.Price,
+		Size:         advice.Position,
+	}
+	tt.pnl = pnl
+	tt.high = advice.Price
+	tt.low = advice.Price
+}
+
+func (tt *tradeTracker) mark(day int, advice domain.Advice, position float64) {
+	tt.trade.marks = append(tt.trade.marks, tradeMark{
+		day:      day,
+		time:     advice.DateTime,
+		price:    advice.Price,
+		position: position,
+		pnl:      tt.pnl,
+		high:     tt.high,
+		low:      tt.low,
+	})
+	tt.high = advice.Price
+	tt.low = advice.Price
+}
+
+func (tt *tradeTracker) close(day int, advice domain.Advice, position float64, open bool) {
+	tt.mark(day, advice, position)
+	var trade = tt.trade.between(-1, len(tt.trade.marks)-1, 0, 0)
+	trade.marks = tt.trade.marks
+	trade.Open = open
+	tt.trades = append(tt.trades, trade)
+	tt.trade = nil
+}
+
+// Конец дня day контракта, lastAdvice - последний совет дня
+func (tt *tradeTracker) endDay(day int, lastAdvice domain.Advice) {
+	if tt.trade != nil {
+		tt.mark(day, lastAdvice, lastAdvice.Position)
+	}
+}
+
+func (tt *tradeTracker) next(
+	day int,
+	candle domain.Candle,
+	advice, lastAdvice domain.Advice,
+	slippage float64,
+	skipPnl bool,
+) {
+	// после праздников ни доход, ни проскальзывание не учитываются, как и в дневных доходностях
+	if skipPnl {
+		slippage = 0
+	}
+	if tt.trade != nil {
+		tt.high = math.Max(tt.high, candle.HighPrice)
+		tt.low = math.Min(tt.low, candle.LowPrice)
+		if !skipPnl {
+			tt.pnl += lastAdvice.Position * (advice.Price - lastAdvice.Price)
+		}
+	}
+	if advice.Position == lastAdvice.Position {
+		return
+	}
+	if tt.trade != nil && sign(advice.Position) != sign(lastAdvice.Position) {
+		tt.pnl -= slippage * advice.Price * math.Abs(lastAdvice.Position)
+		tt.close(day, advice, lastAdvice.Position, false)
+	}
+	if tt.trade == nil && advice.Position != 0 {
+		tt.open(advice, -slippage*advice.Price*math.Abs(advice.Position))
+	} else if tt.trade != nil {
+		tt.pnl -= slippage * advice.Price * math.Abs(advice.Position-lastAdvice.Position)
+	}
+}
+
+// Конец данных: открытая сделка закрывается по последней цене
+func (tt *tradeTracker) finish(day int, lastAdvice domain.Advice) {
+	if tt.trade != nil {
+		tt.close(day, lastAdvice, lastAdvice.Position, true)
+	}
+}
+
+func SingleContractTrades(
+	candles iter.Seq2[domain.Candle, error],
+	advisor domain.Advisor,
+	cost CostModel,
+	skipPnl func(time.Time, time.Time) bool) ([]Trade, error) {
+
+	var _, trades, err = singleContractDays(candles, advisor, cost, skipPnl)
+	return trades, err
+}
+
+func sign(x float64) int {
+	if x > 0 {
+		return 1
+	}
+	if x < 0 {
+		return -1
+	}
+	return 0
+}
+
+func tradesWithLever(source []Trade, lever float64) []Trade {
+	var result = make([]Trade, len(source))
+	for i, trade := range source {
+		trade.Pnl *= lever
+		result[i] = trade
+	}
+	return result
+}
+
+type TradeStatistics struct {
+	Count               int
+	Wins                int
+	WinRate             float64
+	AvgWin              float64
+	AvgLoss             float64
+	ProfitFactor        float64
+	Expectancy          float64
+	LongestLosingStreak int
+	AvgHoldingTime      time.Duration
+	AvgMAE              float64
+	AvgMFE              float64
+}
+
+func computeTradeStatistics(trades []Trade) TradeStatistics {
+	var result = TradeStatistics{Count: len(trades)}
+	if len(trades) == 0 {
+		return result
+	}
+	var grossProfit, grossLoss, totalPnl = 0.0, 0.0, 0.0
+	var losingStreak = 0
+	var holdingTime time.Duration
+	for _, trade := range trades {
+		totalPnl += trade.Pnl
+		holdingTime += trade.HoldingTime()
+		result.AvgMAE += trade.MAE
+		result.AvgMFE += trade.MFE
+		if trade.Pnl > 0 {
+			result.Wins++
+			grossProfit += trade.Pnl
+			losingStreak = 0
+		} else {
+			grossLoss -= trade.Pnl
+			losingStreak++
+			result.LongestLosingStreak = max(result.LongestLosingStreak, losingStreak)
+		}
+	}
+	var n = float64(len(trades))
+	var losses = len(trades) - result.Wins
+	result.WinRate = float64(result.Wins) / n
+	if result.Wins != 0 {
+		result.AvgWin = grossProfit / float64(result.Wins)
+	}
+	if losses != 0 {
+		result.AvgLoss = -grossLoss / float64(losses)
+	}
+	// без убыточных сделок профит-фактор не определен, оставляем 0
+	if grossLoss != 0 {
+		result.ProfitFactor = grossProfit / grossLoss
+	}
+	result.Expectancy = totalPnl / n
+	result.AvgHoldingTime = holdingTime / time.Duration(len(trades))
+	result.AvgMAE /= n
+	result.AvgMFE /= n
+	return result
+}
+
+func tradeStatisticsTable(stat TradeStatistics) reportTable {
+	return reportTable{
+		Title: "Сделки",
+		Rows: [][]string{
+			{"Сделок", fmt.Sprint(stat.Count)},
+			{"Прибыльных сделок", fmt.Sprintf("%.1f%%", stat.WinRate*100)},
+			{"Средняя прибыль", fmt.Sprintf("%.2f%%", stat.AvgWin*100)},
+			{"Средний убыток", fmt.Sprintf("%.2f%%", stat.AvgLoss*100)},
+			{"Профит-фактор", fmt.Sprintf("%.2f", stat.ProfitFactor)},
+			{"Матожидание сделки", fmt.Sprintf("%.2f%%", stat.Expectancy*100)},
+			{"Убыточных сделок подряд", fmt.Sprint(stat.LongestLosingStreak)},
+			{"Среднее время в сделке", fmt.Sprint(stat.AvgHoldingTime.Round(time.Minute))},
+			{"Среднее MAE/MFE", fmt.Sprintf("%.2f%% / %.2f%%", stat.AvgMAE*100, stat.AvgMFE*100)},
+		},
+	}
+}
+
+// Формат определяется по расширению (см. writeJSONOrCSV)
+func WriteTrades(path string, trades []Trade) error {
+	var header = []string{"security", "entry_time", "exit_time", "entry_price", "exit_price",
+		"size", "pnl", "holding_minutes", "mae", "mfe", "open"}
+	var rows [][]string
+	for _, trade := range trades {
+		rows = append(rows, []string{
+			trade.SecurityCode,
+			trade.EntryTime.Format(time.DateTime),
+			trade.ExitTime.Format(time.DateTime),
+			formatFloat(trade.EntryPrice),
+			formatFloat(trade.ExitPrice),
+			formatFloat(trade.Size),
+			formatFloat(trade.Pnl),
+			strconv.Itoa(int(trade.HoldingTime().Minutes())),
+			formatFloat(trade.MAE),
+			formatFloat(trade.MFE),
+			strconv.FormatBool(trade.Open),
+		})
+	}
+	return writeJSONOrCSV(path, trades, header, rows)
+}
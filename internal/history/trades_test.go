@@ -0,0 +1,136 @@
+package history
+
+import (
+	"advisordev/internal/domain"
+	"iter"
+	"math"
+	"testing"
+	"time"
+)
+
+func testCandles(prices ...float64) iter.Seq2[domain.Candle, error] {
+	var start = time.Date(2024, time.January, 10, 10, 0, 0, 0, time.UTC)
+	return func(yield func(domain.Candle, error) bool) {
+		for i, price := range prices {
+			var candle = domain.Candle{
+				SecurityCode: "Si-3.24",
+				DateTime:     start.Add(time.Duration(i) * 5 * time.Minute),
+				OpenPrice:    price,
+				HighPrice:    price + 1,
+				LowPrice:     price - 1,
+				ClosePrice:   price,
+			}
+			if !yield(candle, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Советник с заранее заданными позициями на каждый бар
+func testPositionsAdvisor(positions ...float64) domain.Advisor {
+	var i = 0
+	return func(c domain.Candle) domain.Advice {
+		var advice = domain.Advice{
+			SecurityCode: c.SecurityCode,
+			DateTime:     c.DateTime,
+			Price:        c.ClosePrice,
+			Position:     positions[i],
+		}
+		i++
+		return advice
+	}
+}
+
+func TestSingleContractTrades(t *testing.T) {
+	var trades, err = SingleContractTrades(
+		testCandles(100, 100, 110, 105, 100, 100),
+		testPositionsAdvisor(0, 1, 1, -1, -1, -1),
+		ConstantCost(0),
+		func(time.Time, time.Time) bool { return false })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trades) != 2 {
+		t.Fatal(trades)
+	}
+	var long, short = trades[0], trades[1]
+	if long.Size != 1 || long.EntryPrice != 100 || long.ExitPrice != 105 || math.Abs(long.Pnl-0.05) > 1e-9 {
+		t.Error(long)
+	}
+	if math.Abs(long.MFE-0.11) > 1e-9 || long.MAE != 0 {
+		t.Error(long)
+	}
+	if short.Size != -1 || !short.Open || math.Abs(short.Pnl-5.0/105) > 1e-9 {
+		t.Error(short)
+	}
+
+	var stat = computeTradeStatistics(trades)
+	if stat.Wins != 2 || stat.WinRate != 1 || stat.LongestLosingStreak != 0 {
+		t.Error(stat)
+	}
+}
+
+func TestSingleContractTradesSkipPnl(t *testing.T) {
+	// все бары после "праздников": сделки и дневные доходности без дохода и проскальзывания
+	var skipPnl = func(time.Time, time.Time) bool { return true }
+	days, trades, err := singleContractDays(
+		testCandles(100, 100, 110, 105),
+		testPositionsAdvisor(0, 1, 1, 0),
+		ConstantCost(0.01),
+		skipPnl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 1 || days[0].Sum != 1 || len(trades) != 1 || trades[0].Pnl != 0 {
+		t.Error(days, trades)
+	}
+}
+
+func TestTradeWindow(t *testing.T) {
+	var start = time.Date(2024, time.January, 10, 23, 0, 0, 0, time.UTC)
+	var trade = Trade{
+		SecurityCode: "Si-3.24",
+		EntryTime:    start,
+		EntryPrice:   100,
+		Size:         1,
+		marks: []tradeMark{
+			{day: 0, time: start, price: 100, position: 1, pnl: 0, high: 101, low: 99},
+			{day: 1, time: start.AddDate(0, 0, 1), price: 110, position: 1, pnl: 10, high: 111, low: 99},
+			{day: 2, time: start.AddDate(0, 0, 2), price: 120, position: 1, pnl: 20, high: 121, low: 109},
+		},
+	}
+	var tests = []struct {
+		from, to   int
+		found      bool
+		entryPrice float64
+		exitPrice  float64
+		pnl        float64
+		open       bool
+	}{
+		{0, -1, true, 100, 120, 0.2, false},
+		// переход на следующий контракт после дня 1: сделка обрезается с издержками закрытия
+		{0, 2, true, 100, 110, (10 - 0.11) / 100, true},
+		// на следующем контракте сделка входит в конце дня 1 с издержками открытия
+		{2, -1, true, 110, 120, (10 - 0.11) / 110, false},
+		{1, 2, true, 100, 110, (10 - 0.1 - 0.11) / 100, true},
+		{3, -1, false, 0, 0, 0, false},
+	}
+	for _, test := range tests {
+		result, found, err := trade.window(test.from, test.to, ConstantCost(0.001))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found != test.found {
+			t.Errorf("%+v: found %v", test, found)
+			continue
+		}
+		if !found {
+			continue
+		}
+		if result.EntryPrice != test.entryPrice || result.ExitPrice != test.exitPrice ||
+			math.Abs(result.Pnl-test.pnl) > 1e-9 || result.Open != test.open {
+			t.Errorf("%+v: %+v", test, result)
+		}
+	}
+}
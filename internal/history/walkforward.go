@@ -0,0 +1,148 @@
+package history
+
+import (
+	advisors "advisordev/internal/advisors_sample"
+	"advisordev/internal/domain"
+	"advisordev/internal/moex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+type WalkForwardSettings struct {
+	Optimize OptimizeSettings
+	// Размер окна оптимизации в контрактах
+	InSample int
+	// Размер окна проверки в контрактах. Окна сдвигаются на OutSample.
+	OutSample int
+}
+
+type WalkForwardWindow struct {
+	InSample  []string
+	OutSample []string
+	// Лучший прогон на окне оптимизации, его параметры и плечо применяются к окну проверки
+	Best OptimizationRun
+	// Доходности на окне проверки с плечом Best.Lever
+	Hprs []DateSum
+}
+
+type WalkForwardResult struct {
+	Windows []WalkForwardWindow
+	// Склеенные доходности окон проверки
+	Hprs []DateSum
+}
+
+func WalkForward(
+	candleStorage domain.ICandleStorage,
+	settings WalkForwardSettings,
+) (WalkForwardResult, error) {
+	if settings.InSample <= 0 || settings.OutSample <= 0 {
+		return WalkForwardResult{}, fmt.Errorf("bad walk-forward windows %v/%v", settings.InSample, settings.OutSample)
+	}
+	if !settings.Optimize.MultiContract {
+		return WalkForwardResult{}, fmt.Errorf("walk-forward requires multi-contract range")
+	}
+	var secCodes = securityCodes(settings.Optimize.SecurityName, settings.Optimize.TimeRange, true)
+	if len(secCodes) <= settings.InSample {
+		return WalkForwardResult{}, fmt.Errorf("not enough contracts %v for in-sample window %v", len(secCodes), settings.InSample)
+	}
+
+	var result WalkForwardResult
+	for start := 0; start+settings.InSample < len(secCodes); start += settings.OutSample {
+		var inSample = secCodes[start : start+settings.InSample]
+		var outSample = secCodes[start+settings.InSample : min(len(secCodes), start+settings.InSample+settings.OutSample)]
+		fmt.Println("Walk-forward", inSample[0], inSample[len(inSample)-1], "->", outSample[0], outSample[len(outSample)-1])
+
+		runs, err := optimizeContracts(candleStorage, settings.Optimize, inSample)
+		if err != nil {
+			return WalkForwardResult{}, err
+		}
+		var best = runs[0]
+		if best.Error != "" {
+			return WalkForwardResult{}, fmt.Errorf("walk-forward %v: %v", inSample, best.Error)
+		}
+
+		newAdvisor, err := advisors.TestAdvisor(settings.Optimize.AdvisorName, best.Params)
+		if err != nil {
+			return WalkForwardResult{}, err
+		}
+		// границы окна проверки - переходы по правилу Optimize.Roll с последнего контракта окна оптимизации
+		// и на первый контракт следующего окна, поэтому окна проверки не пересекаются и склеиваются без пропусков
+		var boundary = append([]string{inSample[len(inSample)-1]}, outSample...)
+		var next = ""
+		if k := start + settings.InSample + len(outSample); k < len(secCodes) {
+			next = secCodes[k]
+			boundary = append(boundary, next)
+		}
+		contracts, err := multiContract(
+			candleStorage, newAdvisor, boundary, settings.Optimize.Cost, settings.Optimize.Roll, settings.Optimize.Strict,
+			moex.FortsCalendar.IsAfterHolidays, 1)
+		if err != nil {
+			return WalkForwardResult{}, err
+		}
+		var hprs = outSampleHprs(contracts, boundary[0], next)
+		hprs = hprsWithLever(hprs, best.Lever)
+
+		result.Windows = append(result.Windows, WalkForwardWindow{
+			InSample:  inSample,
+			OutSample: outSample,
+			Best:      best,
+			Hprs:      hprs,
+		})
+		result.Hprs = append(result.Hprs, hprs...)
+	}
+	return result, nil
+}
+
+// Доходности окна проверки: с перехода с контракта prev до перехода на контракт next (пусто - до конца данных).
+// Если у prev нет данных, окно начинается с первого дня данных.
+func outSampleHprs(contracts MultiContractResult, prev, next string) []DateSum {
+	var from, to time.Time
+	for _, roll := range contracts.Rolls {
+		if roll.From == prev {
+			from = roll.Date
+		}
+		if next != "" && roll.To == next {
+			to = roll.Date
+		}
+	}
+	if from.IsZero() && len(contracts.Coverage) != 0 && contracts.Coverage[0].Days != 0 {
+		// с prev не перешли: все дни принадлежат окну оптимизации
+		return nil
+	}
+	var result []DateSum
+	for _, hpr := range contracts.Hprs {
+		if hpr.Date.Before(from) || !to.IsZero() && !hpr.Date.Before(to) {
+			continue
+		}
+		result = append(result, hpr)
+	}
+	return result
+}
+
+func PrintWalkForward(result WalkForwardResult) {
+	var w = newTabWriter(os.Stdout)
+	fmt.Fprintf(w, "Оптимизация\tПроверка\tПараметры\tПлечо\tЦель\tДоходность\t\n")
+	for _, window := range result.Windows {
+		var mark = ""
+		if !window.Best.Feasible {
+			mark = "!"
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%.1f\t%.4f%v\t%.1f%%\t\n",
+			contractRange(window.InSample), contractRange(window.OutSample),
+			window.Best.Params, window.Best.Lever, window.Best.Objective, mark,
+			hprPercent(totalHpr(window.Hprs)))
+	}
+	w.Flush()
+	if len(result.Hprs) != 0 {
+		ReportDailyResults(result.Hprs)
+	}
+}
+
+func contractRange(secCodes []string) string {
+	if len(secCodes) == 1 {
+		return secCodes[0]
+	}
+	return strings.Join([]string{secCodes[0], secCodes[len(secCodes)-1]}, "..")
+}
@@ -0,0 +1,434 @@
+package moex
+
+import (
+	_ "embed"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Версия формата файла календаря
+const CalendarVersion = 1
+
+const (
+	// Вне торговых сессий (выходной, праздник, клиринг между сессиями, ночь)
+	SessionClosed  = ""
+	SessionMorning = "morning"
+	SessionMain    = "main"
+	SessionEvening = "evening"
+)
+
+type CalendarConfig struct {
+	Version int `xml:",attr"`
+	// Годы, для которых перечислены все праздники, например "2009-2026"
+	Years       string             `xml:",attr"`
+	Schedules   []ScheduleConfig   `xml:"Schedule"`
+	Holidays    []CalendarDay      `xml:"Holiday"`
+	WorkDays    []CalendarDay      `xml:"WorkDay"`
+	ShortDays   []ShortDayConfig   `xml:"Short"`
+	Suspensions []SuspensionConfig `xml:"Suspension"`
+}
+
+// Расписание действует с даты From до начала следующего расписания
+type ScheduleConfig struct {
+	From      string           `xml:",attr"`
+	Sessions  []SessionConfig  `xml:"Session"`
+	Clearings []IntervalConfig `xml:"Clearing"`
+}
+
+type SessionConfig struct {
+	Name  string `xml:",attr"`
+	Start string `xml:",attr"`
+	End   string `xml:",attr"`
+}
+
+type IntervalConfig struct {
+	Start string `xml:",attr"`
+	End   string `xml:",attr"`
+}
+
+type CalendarDay struct {
+	Date string `xml:",attr"`
+}
+
+// Сокращенный день: торги заканчиваются в End
+type ShortDayConfig struct {
+	Date string `xml:",attr"`
+	End  string `xml:",attr"`
+}
+
+// Приостановка торгов с From по To включительно
+type SuspensionConfig struct {
+	From string `xml:",attr"`
+	To   string `xml:",attr"`
+}
+
+//go:embed forts_calendar.xml
+var fortsCalendarData []byte
+
+// Календарь FORTS из файла forts_calendar.xml, встроенного в программу
+var FortsCalendar = mustParseCalendar(fortsCalendarData)
+
+// Дата без времени, чтобы не зависеть от часового пояса
+type civilDate struct {
+	year  int
+	month time.Month
+	day   int
+}
+
+func dateOf(t time.Time) civilDate {
+	var y, m, d = t.Date()
+	return civilDate{y, m, d}
+}
+
+func (d civilDate) time(loc *time.Location) time.Time {
+	return time.Date(d.year, d.month, d.day, 0, 0, 0, 0, loc)
+}
+
+// Интервал времени дня в минутах от полуночи [start, end)
+type dayInterval struct {
+	start int
+	end   int
+}
+
+func (i dayInterval) contains(minute int) bool {
+	return i.start <= minute && minute < i.end
+}
+
+type tradingSession struct {
+	name string
+	dayInterval
+}
+
+type schedule struct {
+	from      civilDate
+	sessions  []tradingSession
+	clearings []dayInterval
+}
+
+// Торговый календарь: праздники, переносы, сокращенные дни, приостановки и расписание сессий по датам.
+// Время сессий - в часовом поясе момента (бары хранятся в московском времени).
+type Calendar struct {
+	schedules   []schedule
+	holidays    map[civilDate]bool
+	workDays    map[civilDate]bool
+	shortDays   map[civilDate]int
+	suspensions map[civilDate]bool
+	firstYear   int
+	lastYear    int
+}
+
+func LoadCalendar(filePath string) (*Calendar, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCalendar(data)
+}
+
+func ParseCalendar(data []byte) (*Calendar, error) {
+	var config CalendarConfig
+	var err = xml.Unmarshal(data, &config)
+	if err != nil {
+		return nil, err
+	}
+	return NewCalendar(config)
+}
+
+func mustParseCalendar(data []byte) *Calendar {
+	var calendar, err = ParseCalendar(data)
+	if err != nil {
+		panic(fmt.Errorf("forts calendar: %w", err))
+	}
+	return calendar
+}
+
+func NewCalendar(config CalendarConfig) (*Calendar, error) {
+	if config.Version != CalendarVersion {
+		return nil, fmt.Errorf("unsupported calendar version %v", config.Version)
+	}
+	var calendar = &Calendar{
+		holidays:    make(map[civilDate]bool),
+		workDays:    make(map[civilDate]bool),
+		shortDays:   make(map[civilDate]int),
+		suspensions: make(map[civilDate]bool),
+	}
+	var err error
+	calendar.firstYear, calendar.lastYear, err = parseYears(config.Years)
+	if err != nil {
+		return nil, err
+	}
+	for _, scheduleConfig := range config.Schedules {
+		item, err := parseSchedule(scheduleConfig)
+		if err != nil {
+			return nil, err
+		}
+		calendar.schedules = append(calendar.schedules, item)
+	}
+	if len(calendar.schedules) == 0 {
+		return nil, fmt.Errorf("calendar: no schedules")
+	}
+	sort.Slice(calendar.schedules, func(i, j int) bool {
+		return calendar.schedules[i].from.time(time.UTC).Before(calendar.schedules[j].from.time(time.UTC))
+	})
+	for _, day := range config.Holidays {
+		date, err := parseCivilDate(day.Date)
+		if err != nil {
+			return nil, err
+		}
+		calendar.holidays[date] = true
+	}
+	for _, day := range config.WorkDays {
+		date, err := parseCivilDate(day.Date)
+		if err != nil {
+			return nil, err
+		}
+		calendar.workDays[date] = true
+	}
+	for _, day := range config.ShortDays {
+		date, err := parseCivilDate(day.Date)
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseClock(day.End)
+		if err != nil {
+			return nil, err
+		}
+		calendar.shortDays[date] = end
+	}
+	for _, suspension := range config.Suspensions {
+		from, err := parseCivilDate(suspension.From)
+		if err != nil {
+			return nil, err
+		}
+		to, err := parseCivilDate(suspension.To)
+		if err != nil {
+			return nil, err
+		}
+		for d := from.time(time.UTC); !d.After(to.time(time.UTC)); d = d.AddDate(0, 0, 1) {
+			calendar.suspensions[dateOf(d)] = true
+		}
+	}
+	return calendar, nil
+}
+
+func parseYears(s string) (int, int, error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+	var first, last, found = strings.Cut(s, "-")
+	if !found {
+		last = first
+	}
+	firstYear, err := strconv.Atoi(strings.TrimSpace(first))
+	if err != nil {
+		return 0, 0, fmt.Errorf("calendar: bad years %q", s)
+	}
+	lastYear, err := strconv.Atoi(strings.TrimSpace(last))
+	if err != nil || lastYear < firstYear {
+		return 0, 0, fmt.Errorf("calendar: bad years %q", s)
+	}
+	return firstYear, lastYear, nil
+}
+
+func parseSchedule(config ScheduleConfig) (schedule, error) {
+	from, err := parseCivilDate(config.From)
+	if err != nil {
+		return schedule{}, err
+	}
+	var result = schedule{from: from}
+	for _, sessionConfig := range config.Sessions {
+		switch sessionConfig.Name {
+		case SessionMorning, SessionMain, SessionEvening:
+		default:
+			return schedule{}, fmt.Errorf("calendar: bad session %q", sessionConfig.Name)
+		}
+		interval, err := parseInterval(sessionConfig.Start, sessionConfig.End)
+		if err != nil {
+			return schedule{}, err
+		}
+		result.sessions = append(result.sessions, tradingSession{name: sessionConfig.Name, dayInterval: interval})
+	}
+	for _, clearingConfig := range config.Clearings {
+		interval, err := parseInterval(clearingConfig.Start, clearingConfig.End)
+		if err != nil {
+			return schedule{}, err
+		}
+		result.clearings = append(result.clearings, interval)
+	}
+	return result, nil
+}
+
+func parseCivilDate(s string) (civilDate, error) {
+	var d, err = time.Parse(time.DateOnly, s)
+	if err != nil {
+		return civilDate{}, fmt.Errorf("calendar: bad date %q", s)
+	}
+	return dateOf(d), nil
+}
+
+// "15:04" -> минуты от полуночи
+func parseClock(s string) (int, error) {
+	var t, err = time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("calendar: bad time %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func parseInterval(start, end string) (dayInterval, error) {
+	startMinute, err := parseClock(start)
+	if err != nil {
+		return dayInterval{}, err
+	}
+	endMinute, err := parseClock(end)
+	if err != nil {
+		return dayInterval{}, err
+	}
+	if endMinute <= startMinute {
+		return dayInterval{}, fmt.Errorf("calendar: bad interval %v-%v", start, end)
+	}
+	return dayInterval{start: startMinute, end: endMinute}, nil
+}
+
+func (c *Calendar) covers(date civilDate) bool {
+	return c.firstYear <= date.year && date.year <= c.lastYear
+}
+
+// Торгуется ли дата. Вне лет календаря торгуются все будние дни.
+func (c *Calendar) IsTradingDay(date time.Time) bool {
+	var d = dateOf(date)
+	if c.suspensions[d] {
+		return false
+	}
+	if c.workDays[d] {
+		return true
+	}
+	if isWeekend(date.Weekday()) {
+		return false
+	}
+	return !c.holidays[d]
+}
+
+// Праздник - будний день без торгов, кроме приостановки торгов.
+// Вне лет календаря праздником считается любой будний день, о котором календарь ничего не знает.
+func (c *Calendar) IsHoliday(date time.Time) bool {
+	var d = dateOf(date)
+	if c.suspensions[d] || c.workDays[d] || isWeekend(date.Weekday()) {
+		return false
+	}
+	return c.holidays[d] || !c.covers(d)
+}
+
+// Были ли праздники между днями l и r (не включая их). Во время приостановки торгов выйти из позиции нельзя,
+// поэтому она праздником не считается. Сигнатура подходит для skipPnl тестирования на истории.
+func (c *Calendar) IsAfterHolidays(l, r time.Time) bool {
+	var from = time.Date(l.Year(), l.Month(), l.Day(), 0, 0, 0, 0, l.Location()).AddDate(0, 0, 1)
+	var to = time.Date(r.Year(), r.Month(), r.Day(), 0, 0, 0, 0, r.Location())
+	for d := from; d.Before(to); d = d.AddDate(0, 0, 1) {
+		if c.IsHoliday(d) {
+			return true
+		}
+	}
+	return false
+}
+
+// Первый торговый день после date
+func (c *Calendar) NextTradingDay(date time.Time) time.Time {
+	var d = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	for {
+		d = d.AddDate(0, 0, 1)
+		if c.IsTradingDay(d) {
+			return d
+		}
+	}
+}
+
+// Последний торговый день до date
+func (c *Calendar) PrevTradingDay(date time.Time) time.Time {
+	var d = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	for {
+		d = d.AddDate(0, 0, -1)
+		if c.IsTradingDay(d) {
+			return d
+		}
+	}
+}
+
+func (c *Calendar) schedule(date civilDate) schedule {
+	var i = sort.Search(len(c.schedules), func(i int) bool {
+		return c.schedules[i].from.time(time.UTC).After(date.time(time.UTC))
+	})
+	return c.schedules[max(0, i-1)]
+}
+
+// Сессия, к которой относится момент: SessionMorning, SessionMain, SessionEvening или SessionClosed.
+// Промежуточный клиринг внутри сессии относится к сессии (см. IsClearing).
+func (c *Calendar) Session(t time.Time) string {
+	if !c.IsTradingDay(t) {
+		return SessionClosed
+	}
+	var d = dateOf(t)
+	var minute = t.Hour()*60 + t.Minute()
+	if end, found := c.shortDays[d]; found && minute >= end {
+		return SessionClosed
+	}
+	for _, session := range c.schedule(d).sessions {
+		if session.contains(minute) {
+			return session.name
+		}
+	}
+	return SessionClosed
+}
+
+// Идет ли клиринг (промежуточный или между основной и вечерней сессиями)
+func (c *Calendar) IsClearing(t time.Time) bool {
+	if !c.IsTradingDay(t) {
+		return false
+	}
+	var minute = t.Hour()*60 + t.Minute()
+	for _, clearing := range c.schedule(dateOf(t)).clearings {
+		if clearing.contains(minute) {
+			return true
+		}
+	}
+	return false
+}
+
+// Торговый день, к которому относится момент: вечерняя сессия относится к следующему торговому дню.
+// Вне сессий - ближайший торговый день, который еще не закончился.
+func (c *Calendar) TradingDate(t time.Time) time.Time {
+	var date = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if !c.IsTradingDay(date) {
+		return c.NextTradingDay(date)
+	}
+	var session = c.Session(t)
+	if session == SessionEvening {
+		return c.NextTradingDay(date)
+	}
+	if session == SessionClosed {
+		var minute = t.Hour()*60 + t.Minute()
+		var sessions = c.schedule(dateOf(t)).sessions
+		var mainEnd = 0
+		for _, s := range sessions {
+			if s.name == SessionMain {
+				mainEnd = s.end
+			}
+		}
+		if end, found := c.shortDays[dateOf(t)]; found {
+			mainEnd = min(mainEnd, end)
+		}
+		if minute >= mainEnd {
+			return c.NextTradingDay(date)
+		}
+	}
+	return date
+}
+
+func isWeekend(weekday time.Weekday) bool {
+	return weekday == time.Saturday || weekday == time.Sunday
+}
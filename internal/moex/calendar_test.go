@@ -0,0 +1,62 @@
+package moex
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFortsCalendarSession(t *testing.T) {
+	var tests = []struct {
+		time    string
+		session string
+	}{
+		{"2023-06-13 09:30", SessionClosed},
+		{"2024-06-13 09:30", SessionMorning},
+		{"2024-06-13 10:00", SessionMain},
+		{"2024-06-13 14:02", SessionMain},
+		{"2024-06-13 18:50", SessionClosed},
+		{"2024-06-13 19:05", SessionEvening},
+		{"2024-06-12 12:00", SessionClosed},
+		{"2024-06-15 12:00", SessionClosed},
+		{"2022-03-01 12:00", SessionClosed},
+		{"2024-12-30 19:30", SessionEvening},
+		{"2024-12-31 19:30", SessionClosed},
+	}
+	for _, test := range tests {
+		var d, _ = time.ParseInLocation("2006-01-02 15:04", test.time, TimeZone)
+		var session = FortsCalendar.Session(d)
+		if session != test.session {
+			t.Error(test, session)
+		}
+	}
+}
+
+func TestFortsCalendarHolidays(t *testing.T) {
+	var date = func(s string) time.Time {
+		var d, _ = time.ParseInLocation(time.DateOnly, s, TimeZone)
+		return d
+	}
+	var tests = []struct {
+		l, r     string
+		expected bool
+	}{
+		{"2024-06-07", "2024-06-10", false},
+		{"2024-06-11", "2024-06-13", true},
+		{"2024-12-31", "2025-01-03", true},
+		// приостановка торгов в 2022 году
+		{"2022-02-25", "2022-03-24", false},
+	}
+	for _, test := range tests {
+		var result = FortsCalendar.IsAfterHolidays(date(test.l), date(test.r))
+		if result != test.expected {
+			t.Error(test, result)
+		}
+	}
+	if d := FortsCalendar.NextTradingDay(date("2024-12-31")); !d.Equal(date("2025-01-03")) {
+		t.Error("next trading day", d)
+	}
+	var evening = time.Date(2024, time.June, 11, 20, 0, 0, 0, TimeZone)
+	if d := FortsCalendar.TradingDate(evening); !d.Equal(date("2024-06-13")) {
+		t.Error("trading date", d)
+	}
+}
@@ -0,0 +1,31 @@
+package moex
+
+import (
+	"time"
+)
+
+// Последний торговый день фьючерса вида name-month.year, например Si-3.25, в часовом поясе биржи.
+// С 1 июля 2015 новые серии исполняются в 3-й четверг месяца (первые такие - с исполнением в 2016),
+// до этого - 15-го числа. Если день не торговый, то следующий торговый день.
+func ExpirationDate(securityCode string) (time.Time, error) {
+	_, year, month, err := parseSecurityName(securityCode)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var d time.Time
+	if year >= 2016 {
+		d = thirdThursday(year, month)
+	} else {
+		d = time.Date(year, month, 15, 0, 0, 0, 0, TimeZone)
+	}
+	if !FortsCalendar.IsTradingDay(d) {
+		d = FortsCalendar.NextTradingDay(d)
+	}
+	return d, nil
+}
+
+func thirdThursday(year int, month time.Month) time.Time {
+	var d = time.Date(year, month, 1, 0, 0, 0, 0, TimeZone)
+	var offset = (int(time.Thursday) - int(d.Weekday()) + 7) % 7
+	return d.AddDate(0, 0, offset+14)
+}
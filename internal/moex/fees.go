@@ -0,0 +1,143 @@
+package moex
+
+import (
+	"advisordev/internal/domain"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Версия формата файла тарифов
+const FeeConfigVersion = 1
+
+type FeeConfig struct {
+	Version   int                 `xml:",attr"`
+	Contracts []FeeContractConfig `xml:"Contract"`
+	Tariffs   []TariffConfig      `xml:"Tariff"`
+}
+
+// Тип контракта по базовому активу (Si-3.25 -> Si)
+type FeeContractConfig struct {
+	Base string `xml:",attr"`
+	Type string `xml:",attr"`
+	// Проскальзывание, шагов цены
+	Slippage float64 `xml:",attr"`
+}
+
+// Тариф действует с даты From до начала следующего тарифа
+type TariffConfig struct {
+	From string `xml:",attr"`
+	// Комиссия брокера, руб. за контракт
+	Broker float64         `xml:",attr"`
+	Fees   []FeeRateConfig `xml:"Fee"`
+}
+
+// Биржевой и клиринговый сборы по типу контракта, % от стоимости контракта
+type FeeRateConfig struct {
+	Type     string  `xml:",attr"`
+	Exchange float64 `xml:",attr"`
+	Clearing float64 `xml:",attr"`
+}
+
+func LoadFeeConfig(filePath string) (FeeConfig, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return FeeConfig{}, err
+	}
+	var config FeeConfig
+	err = xml.Unmarshal(data, &config)
+	if err != nil {
+		return FeeConfig{}, err
+	}
+	return config, nil
+}
+
+type tariff struct {
+	from   time.Time
+	broker float64
+	fees   map[string]FeeRateConfig
+}
+
+// Дата сделки раньше первого тарифа
+var ErrTariffNotFound = errors.New("fee: tariff not found")
+
+// Издержки на сделку по тарифам биржи и брокера
+type FeeModel struct {
+	securityInformator domain.ISecurityInformator
+	contracts          map[string]FeeContractConfig
+	tariffs            []tariff
+}
+
+func NewFeeModel(config FeeConfig, securityInformator domain.ISecurityInformator) (*FeeModel, error) {
+	if config.Version != FeeConfigVersion {
+		return nil, fmt.Errorf("unsupported fee config version %v", config.Version)
+	}
+	var model = &FeeModel{
+		securityInformator: securityInformator,
+		contracts:          make(map[string]FeeContractConfig),
+	}
+	for _, contract := range config.Contracts {
+		model.contracts[contract.Base] = contract
+	}
+	for _, tariffConfig := range config.Tariffs {
+		from, err := time.ParseInLocation(time.DateOnly, tariffConfig.From, TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("bad tariff date %q %w", tariffConfig.From, err)
+		}
+		var t = tariff{
+			from:   from,
+			broker: tariffConfig.Broker,
+			fees:   make(map[string]FeeRateConfig),
+		}
+		for _, fee := range tariffConfig.Fees {
+			t.fees[fee.Type] = fee
+		}
+		model.tariffs = append(model.tariffs, t)
+	}
+	sort.Slice(model.tariffs, func(i, j int) bool {
+		return model.tariffs[i].from.Before(model.tariffs[j].from)
+	})
+	return model, nil
+}
+
+func (m *FeeModel) tariff(dateTime time.Time) (tariff, bool) {
+	var i = sort.Search(len(m.tariffs), func(i int) bool {
+		return m.tariffs[i].from.After(dateTime)
+	})
+	if i == 0 {
+		return tariff{}, false
+	}
+	return m.tariffs[i-1], true
+}
+
+// Издержки на покупку или продажу одного контракта в долях стоимости контракта
+func (m *FeeModel) Cost(securityCode string, dateTime time.Time, price float64) (float64, error) {
+	var base, _, _ = strings.Cut(securityCode, "-")
+	contract, found := m.contracts[base]
+	if !found {
+		return 0, fmt.Errorf("fee: contract type not found %v", securityCode)
+	}
+	t, found := m.tariff(dateTime)
+	if !found {
+		return 0, fmt.Errorf("%w %v %v", ErrTariffNotFound, securityCode, dateTime)
+	}
+	fee, found := t.fees[contract.Type]
+	if !found {
+		return 0, fmt.Errorf("fee: fee not found %v %v", contract.Type, t.from.Format(time.DateOnly))
+	}
+	security, err := m.securityInformator.GetSecurityInfo(securityCode)
+	if err != nil {
+		return 0, err
+	}
+	// Lever = PriceStepCost/PriceStep, стоимость контракта в рублях
+	var contractValue = price * security.Lever
+	if contractValue <= 0 {
+		return 0, fmt.Errorf("fee: bad contract value %v %v", securityCode, price)
+	}
+	var perContract = t.broker + contract.Slippage*security.PriceStepCost
+	return (fee.Exchange+fee.Clearing)*0.01 + perContract/contractValue, nil
+}
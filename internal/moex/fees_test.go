@@ -0,0 +1,45 @@
+package moex
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFeeModelCost(t *testing.T) {
+	var fees, err = NewFeeModel(FeeConfig{
+		Version:   FeeConfigVersion,
+		Contracts: []FeeContractConfig{{Base: "Si", Type: "currency", Slippage: 2}},
+		Tariffs: []TariffConfig{
+			{From: "2020-01-01", Broker: 1, Fees: []FeeRateConfig{{Type: "currency", Exchange: 0.01, Clearing: 0.01}}},
+			{From: "2015-01-01", Broker: 3, Fees: []FeeRateConfig{{Type: "currency", Exchange: 0.02, Clearing: 0}}},
+		},
+	}, NewFortsSecurityInformator())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		securityCode string
+		date         time.Time
+		expected     float64
+	}{
+		{securityCode: "Si-3.24", date: time.Date(2024, 1, 10, 0, 0, 0, 0, TimeZone), expected: 0.0002 + 3.0/100000},
+		{securityCode: "Si-3.16", date: time.Date(2016, 1, 10, 0, 0, 0, 0, TimeZone), expected: 0.0002 + 5.0/100000},
+	}
+	for _, test := range tests {
+		cost, err := fees.Cost(test.securityCode, test.date, 100000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(cost-test.expected) > 1e-12 {
+			t.Error(test, cost)
+		}
+	}
+	if _, err = fees.Cost("Si-3.14", time.Date(2014, 1, 10, 0, 0, 0, 0, TimeZone), 100000); !errors.Is(err, ErrTariffNotFound) {
+		t.Error("expected error before first tariff")
+	}
+	if _, err = fees.Cost("BR-3.24", time.Date(2024, 1, 10, 0, 0, 0, 0, TimeZone), 80); err == nil {
+		t.Error("expected error for unknown contract")
+	}
+}
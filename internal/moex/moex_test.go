@@ -1,6 +1,9 @@
 package moex
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestEncodeSecurity(t *testing.T) {
 	var tests = []struct {
@@ -15,6 +18,18 @@ func TestEncodeSecurity(t *testing.T) {
 			name: "CNY-12.22",
 			code: "CRZ2",
 		},
+		{
+			name: "RTS-9.25",
+			code: "RIU5",
+		},
+		{
+			name: "BR-1.26",
+			code: "BRF6",
+		},
+		{
+			name: "USDRUBF",
+			code: "USDRUBF",
+		},
 	}
 
 	for _, test := range tests {
@@ -29,3 +44,81 @@ func TestEncodeSecurity(t *testing.T) {
 		}
 	}
 }
+
+func TestEncodeSecurityErrors(t *testing.T) {
+	for _, name := range []string{"Si", "Si-3", "Si-13.25", "Si-3.x5", "XYZ-3.25", "-3.25", ""} {
+		var _, err = EncodeSecurity(name)
+		if err == nil {
+			t.Error(name, "expected error")
+		}
+	}
+}
+
+func TestDecodeSecurity(t *testing.T) {
+	var tests = []struct {
+		code string
+		date time.Time
+		name string
+	}{
+		{"SiH7", time.Date(2017, time.January, 10, 0, 0, 0, 0, TimeZone), "Si-3.17"},
+		{"SiH7", time.Date(2025, time.January, 10, 0, 0, 0, 0, TimeZone), "Si-3.27"},
+		// истекший недавно контракт
+		{"RIZ4", time.Date(2025, time.March, 1, 0, 0, 0, 0, TimeZone), "RTS-12.24"},
+		{"CRF0", time.Date(2029, time.June, 1, 0, 0, 0, 0, TimeZone), "CNY-1.30"},
+		{"GDQ5", time.Date(2025, time.June, 1, 0, 0, 0, 0, TimeZone), "GOLD-8.25"},
+		{"IMOEXF", time.Date(2025, time.June, 1, 0, 0, 0, 0, TimeZone), "IMOEXF"},
+		{"SiA7", time.Time{}, ""},
+		{"ZZH7", time.Time{}, ""},
+		{"SiH", time.Time{}, ""},
+		{"SiHx", time.Time{}, ""},
+	}
+	for _, test := range tests {
+		var name, err = DecodeSecurity(test.code, test.date)
+		if test.name == "" {
+			if err == nil {
+				t.Error(test, "expected error")
+			}
+			continue
+		}
+		if err != nil || name != test.name {
+			t.Error(test, name, err)
+			continue
+		}
+		if code, _ := EncodeSecurity(name); code != test.code {
+			t.Error(test, code)
+		}
+	}
+}
+
+func TestExpirationDate(t *testing.T) {
+	var tests = []struct {
+		securityCode string
+		expiration   string
+	}{
+		{"Si-9.15", "2015-09-15"},
+		// 15.06.2014 - воскресенье
+		{"Si-6.14", "2014-06-16"},
+		{"Si-3.16", "2016-03-17"},
+		{"Si-12.24", "2024-12-19"},
+		{"CNY-3.25", "2025-03-20"},
+		{"Si-13.25", ""},
+		{"Si-3.2025", ""},
+		{"SiH5", ""},
+	}
+	for _, test := range tests {
+		var d, err = ExpirationDate(test.securityCode)
+		if test.expiration == "" {
+			if err == nil {
+				t.Error(test, "expected error")
+			}
+			continue
+		}
+		if err != nil {
+			t.Error(test, err)
+			continue
+		}
+		if d.Format(time.DateOnly) != test.expiration || d.Location() != TimeZone {
+			t.Error(test, d)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package moex
+
+import (
+	"advisordev/internal/domain"
+	_ "embed"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+)
+
+// Версия формата справочника инструментов
+const SecurityConfigVersion = 1
+
+type SecurityConfig struct {
+	XMLName    xml.Name             `xml:"root"`
+	Version    int                  `xml:",attr"`
+	Securities []SecurityItemConfig `xml:"Security"`
+}
+
+// Параметры фьючерсов базового актива (Si-3.25 -> Si) или вечного фьючерса (USDRUBF)
+type SecurityItemConfig struct {
+	Base           string  `xml:",attr"`
+	ClassCode      string  `xml:",attr"`
+	PricePrecision int     `xml:",attr"`
+	PriceStep      float64 `xml:",attr"`
+	PriceStepCost  float64 `xml:",attr"`
+	Lot            int     `xml:",attr"`
+	// Если 0, то PriceStepCost/PriceStep
+	Lever float64 `xml:",attr"`
+}
+
+//go:embed securities.xml
+var fortsSecuritiesData []byte
+
+func LoadSecurityConfig(filePath string) (SecurityConfig, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return SecurityConfig{}, err
+	}
+	return parseSecurityConfig(data)
+}
+
+func parseSecurityConfig(data []byte) (SecurityConfig, error) {
+	var config SecurityConfig
+	var err = xml.Unmarshal(data, &config)
+	if err != nil {
+		return SecurityConfig{}, err
+	}
+	return config, nil
+}
+
+func SaveSecurityConfig(filePath string, config SecurityConfig) error {
+	data, err := xml.MarshalIndent(config, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, append([]byte(xml.Header), append(data, '\n')...), 0644)
+}
+
+type SecurityInformator struct {
+	securities map[string]SecurityItemConfig
+}
+
+// Справочник из файла securities.xml, встроенного в программу
+func FortsSecurityConfig() SecurityConfig {
+	config, err := parseSecurityConfig(fortsSecuritiesData)
+	if err != nil {
+		panic(fmt.Errorf("forts securities: %w", err))
+	}
+	return config
+}
+
+// Справочник из файла. Если файла нет, то встроенный в программу.
+func LoadSecurityConfigOrDefault(filePath string) (SecurityConfig, error) {
+	config, err := LoadSecurityConfig(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return FortsSecurityConfig(), nil
+	}
+	return config, err
+}
+
+func NewFortsSecurityInformator() *SecurityInformator {
+	si, err := NewSecurityInformator(FortsSecurityConfig())
+	if err != nil {
+		panic(fmt.Errorf("forts securities: %w", err))
+	}
+	return si
+}
+
+func LoadSecurityInformator(filePath string) (*SecurityInformator, error) {
+	config, err := LoadSecurityConfigOrDefault(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return NewSecurityInformator(config)
+}
+
+func NewSecurityInformator(config SecurityConfig) (*SecurityInformator, error) {
+	if config.Version != SecurityConfigVersion {
+		return nil, fmt.Errorf("unsupported securities version %v", config.Version)
+	}
+	var securities = make(map[string]SecurityItemConfig, len(config.Securities))
+	for _, item := range config.Securities {
+		if item.Base == "" || item.ClassCode == "" {
+			return nil, fmt.Errorf("security base and class code required %+v", item)
+		}
+		if !(item.PriceStep > 0 && item.PriceStepCost > 0) {
+			return nil, fmt.Errorf("bad price step %v", item.Base)
+		}
+		if _, found := securities[item.Base]; found {
+			return nil, fmt.Errorf("duplicate security %v", item.Base)
+		}
+		securities[item.Base] = item
+	}
+	return &SecurityInformator{securities: securities}, nil
+}
+
+func (si *SecurityInformator) GetSecurityInfo(securityName string) (domain.SecurityInfo, error) {
+	var base = securityBase(securityName)
+	item, found := si.securities[base]
+	if !found {
+		return domain.SecurityInfo{}, fmt.Errorf("secInfo not found %v", securityName)
+	}
+	securityCode, err := EncodeSecurity(securityName)
+	if err != nil {
+		return domain.SecurityInfo{}, err
+	}
+	var lever = item.Lever
+	if lever == 0 {
+		lever = item.PriceStepCost / item.PriceStep
+	}
+	var lot = item.Lot
+	if lot == 0 {
+		lot = 1
+	}
+	return domain.SecurityInfo{
+		Name:           securityName,
+		ClassCode:      item.ClassCode,
+		Code:           securityCode,
+		PricePrecision: item.PricePrecision,
+		PriceStep:      item.PriceStep,
+		PriceStepCost:  item.PriceStepCost,
+		Lot:            lot,
+		Lever:          lever,
+	}, nil
+}
+
+// Si-3.25 -> Si, USDRUBF -> USDRUBF
+func securityBase(securityName string) string {
+	var base, _, _ = strings.Cut(securityName, "-")
+	return base
+}
+
+// Параметры инструмента из торговой системы
+type SecurityParams struct {
+	PricePrecision int
+	PriceStep      float64
+	PriceStepCost  float64
+	Lot            int
+}
+
+type ISecurityParamsSource interface {
+	SecurityParams(classCode, securityCode string) (SecurityParams, error)
+}
+
+// Обновляет параметры инструментов по ближайшему торгуемому на дату date контракту
+// (месячному или квартальному). Плечо не меняется: заданное сохраняется, нулевое вычисляется при чтении справочника.
+func RefreshSecurityConfig(
+	config SecurityConfig,
+	source ISecurityParamsSource,
+	date time.Time,
+) (SecurityConfig, error) {
+	var result = config
+	result.Securities = make([]SecurityItemConfig, len(config.Securities))
+	for i, item := range config.Securities {
+		params, err := nearestSecurityParams(item, source, date)
+		if err != nil {
+			return SecurityConfig{}, err
+		}
+		item.PricePrecision = params.PricePrecision
+		item.PriceStep = params.PriceStep
+		item.PriceStepCost = params.PriceStepCost
+		item.Lot = params.Lot
+		result.Securities[i] = item
+	}
+	return result, nil
+}
+
+func nearestSecurityParams(
+	item SecurityItemConfig,
+	source ISecurityParamsSource,
+	date time.Time,
+) (SecurityParams, error) {
+	var candidates []string
+	if isPerpetual(item.Base) {
+		candidates = []string{item.Base}
+	} else {
+		for i := 0; i < 4; i++ {
+			var d = time.Date(date.Year(), date.Month()+time.Month(i), 1, 0, 0, 0, 0, TimeZone)
+			candidates = append(candidates, fmt.Sprintf("%v-%v.%02d", item.Base, int(d.Month()), d.Year()%100))
+		}
+	}
+	var errs []error
+	for _, securityName := range candidates {
+		securityCode, err := EncodeSecurity(securityName)
+		if err != nil {
+			return SecurityParams{}, err
+		}
+		params, err := source.SecurityParams(item.ClassCode, securityCode)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", securityCode, err))
+			continue
+		}
+		if !(params.PriceStep > 0 && params.PriceStepCost > 0) {
+			errs = append(errs, fmt.Errorf("%v: bad price step", securityCode))
+			continue
+		}
+		return params, nil
+	}
+	return SecurityParams{}, fmt.Errorf("security params not found %v: %w", item.Base, errors.Join(errs...))
+}
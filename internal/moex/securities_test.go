@@ -0,0 +1,79 @@
+package moex
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSecurityInformator(t *testing.T) {
+	var si = NewFortsSecurityInformator()
+	security, err := si.GetSecurityInfo("CNY-3.25")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if security.Code != "CRH5" || security.PriceStep != 0.001 || security.Lever != 1000 || security.Lot != 1 {
+		t.Errorf("security %+v", security)
+	}
+	if _, err := si.GetSecurityInfo("GOLD-3.25"); err == nil {
+		t.Error("GOLD-3.25: expected error")
+	}
+}
+
+type testSecurityParamsSource map[string]SecurityParams
+
+func (s testSecurityParamsSource) SecurityParams(classCode, securityCode string) (SecurityParams, error) {
+	params, found := s[securityCode]
+	if !found {
+		return SecurityParams{}, errors.New("security not found")
+	}
+	return params, nil
+}
+
+func TestRefreshSecurityConfig(t *testing.T) {
+	var config = SecurityConfig{
+		Version: SecurityConfigVersion,
+		Securities: []SecurityItemConfig{
+			{Base: "GOLD", ClassCode: FuturesClassCode, PriceStep: 1, PriceStepCost: 1},
+			// заданное плечо не пересчитывается
+			{Base: "Si", ClassCode: FuturesClassCode, PriceStep: 1, PriceStepCost: 1, Lever: 1000},
+		},
+	}
+	// в ноябре ближайший квартальный контракт - декабрьский
+	var source = testSecurityParamsSource{
+		"GDZ5": {PricePrecision: 1, PriceStep: 0.1, PriceStepCost: 8, Lot: 1},
+		"SiZ5": {PricePrecision: 0, PriceStep: 1, PriceStepCost: 1, Lot: 1},
+	}
+	refreshed, err := RefreshSecurityConfig(config, source, time.Date(2025, time.November, 10, 0, 0, 0, 0, TimeZone))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// нулевое плечо не записывается, чтобы следовать за стоимостью шага
+	if refreshed.Securities[0].Lever != 0 {
+		t.Errorf("lever %v", refreshed.Securities[0].Lever)
+	}
+	var path = filepath.Join(t.TempDir(), "securities.xml")
+	err = SaveSecurityConfig(path, refreshed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	si, err := LoadSecurityInformator(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	security, err := si.GetSecurityInfo("GOLD-3.26")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if security.Code != "GDH6" || security.PriceStep != 0.1 || security.PriceStepCost != 8 || security.Lever != 80 {
+		t.Errorf("security %+v", security)
+	}
+	security, err = si.GetSecurityInfo("Si-12.25")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if security.Lever != 1000 {
+		t.Errorf("security %+v", security)
+	}
+}
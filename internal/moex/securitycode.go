@@ -0,0 +1,146 @@
+package moex
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Коды месяцев исполнения: F - январь, ..., Z - декабрь
+const monthCodes = "FGHJKMNQUVXZ"
+
+// Краткие коды базовых активов фьючерсов
+// http://moex.com/s205
+var shortCodes = map[string]string{
+	// индексы
+	"RTS": "RI",
+	"MIX": "MX",
+	"MXI": "MM",
+	"RVI": "VI",
+	// валюта
+	"Si":   "Si",
+	"Eu":   "Eu",
+	"ED":   "ED",
+	"CNY":  "CR",
+	"GBPU": "GU",
+	"AUDU": "AU",
+	"UCAD": "CA",
+	"UCHF": "CF",
+	"UJPY": "JP",
+	"UTRY": "TR",
+	// товары
+	"GOLD": "GD",
+	"SILV": "SV",
+	"PLT":  "PT",
+	"PLD":  "PD",
+	"BR":   "BR",
+	"NG":   "NG",
+	// акции
+	"SBRF": "SR",
+	"SBPR": "SP",
+	"GAZR": "GZ",
+	"LKOH": "LK",
+	"ROSN": "RN",
+	"VTBR": "VB",
+	"GMKR": "GK",
+	"MGNT": "MN",
+	"NOTK": "NK",
+	"TATN": "TT",
+	"SNGR": "SN",
+	"SNGP": "SG",
+	"MTSI": "MT",
+	"ALRS": "AL",
+	"AFLT": "AF",
+	"MOEX": "ME",
+	"YNDF": "YN",
+	"PLZL": "PZ",
+	"POLY": "PO",
+	"NLMK": "NM",
+	"CHMF": "CH",
+	"MAGN": "MG",
+	"HYDR": "HY",
+	"RTKM": "RT",
+	"TRNF": "TN",
+	"FEES": "FS",
+	"AFKS": "AK",
+}
+
+var baseAssets = initBaseAssets()
+
+func initBaseAssets() map[string]string {
+	var result = make(map[string]string, len(shortCodes))
+	for name, shortCode := range shortCodes {
+		result[shortCode] = name
+	}
+	return result
+}
+
+// Вечные фьючерсы (USDRUBF, IMOEXF) кодируются так же, как называются
+func isPerpetual(security string) bool {
+	return len(security) > 4 && strings.HasSuffix(security, "F") && !strings.Contains(security, "-")
+}
+
+// Sample: "Si-3.17" -> "SiH7", "RTS-12.24" -> "RIZ4", "BR-1.25" -> "BRF5"
+func EncodeSecurity(securityName string) (string, error) {
+	if isPerpetual(securityName) {
+		return securityName, nil
+	}
+	name, year, month, err := parseSecurityName(securityName)
+	if err != nil {
+		return "", err
+	}
+	shortCode, found := shortCodes[name]
+	if !found {
+		return "", fmt.Errorf("unknown base asset %v", securityName)
+	}
+	return fmt.Sprintf("%v%c%v", shortCode, monthCodes[month-1], year%10), nil
+}
+
+// Sample: "SiH7" -> "Si-3.17" (если date в 2017 году).
+// Код содержит только последнюю цифру года: выбирается год от date.Year()-2 до date.Year()+7,
+// date - дата, когда контракт торговался (или сейчас).
+func DecodeSecurity(securityCode string, date time.Time) (string, error) {
+	if isPerpetual(securityCode) {
+		return securityCode, nil
+	}
+	if len(securityCode) != 4 {
+		return "", fmt.Errorf("bad security code %v", securityCode)
+	}
+	name, found := baseAssets[securityCode[:2]]
+	if !found {
+		return "", fmt.Errorf("unknown base asset %v", securityCode)
+	}
+	var month = strings.IndexByte(monthCodes, securityCode[2]) + 1
+	if month == 0 {
+		return "", fmt.Errorf("bad month code %v", securityCode)
+	}
+	var digit = int(securityCode[3] - '0')
+	if digit < 0 || digit > 9 {
+		return "", fmt.Errorf("bad year %v", securityCode)
+	}
+	var year = date.Year() - 2
+	year += ((digit-year%10)%10 + 10) % 10
+	return fmt.Sprintf("%v-%v.%02d", name, month, year%100), nil
+}
+
+// Sample: "Si-3.25" -> "Si", 2025, март. Год двумя цифрами относится к 2000-м (FORTS с 2001).
+func parseSecurityName(securityName string) (string, int, time.Month, error) {
+	var name, expiry, found = strings.Cut(securityName, "-")
+	if !found || name == "" {
+		return "", 0, 0, fmt.Errorf("bad security name %v", securityName)
+	}
+	monthValue, yearValue, found := strings.Cut(expiry, ".")
+	if !found {
+		return "", 0, 0, fmt.Errorf("bad security name %v", securityName)
+	}
+	month, err := strconv.Atoi(monthValue)
+	if err != nil || month < 1 || month > 12 {
+		return "", 0, 0, fmt.Errorf("bad month %v", securityName)
+	}
+	year, err := strconv.Atoi(yearValue)
+	if err != nil || len(yearValue) != 2 || year < 0 {
+		return "", 0, 0, fmt.Errorf("bad year %v", securityName)
+	}
+	return name, 2000 + year, time.Month(month), nil
+}
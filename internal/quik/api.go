@@ -163,6 +163,8 @@ const (
 	ParamNameBUYDEPO = "BUYDEPO"
 	/// Гарантийное обеспечение продавца
 	ParamNameSELLDEPO = "SELLDEPO"
+	/// Стоимость шага цены
+	ParamNameSTEPPRICE = "STEPPRICE"
 )
 
 // param_value
@@ -186,6 +186,43 @@ func (c *QuikConnector) LastPrice(security domain.SecurityInfo) (float64, error)
 	return AsFloat64(lastPriceParam["param_value"])
 }
 
+// Параметры инструмента для справочника moex.SecurityConfig
+func (c *QuikConnector) SecurityParams(classCode, securityCode string) (moex.SecurityParams, error) {
+	info, err := GetSecurityInfo(c.quikService, classCode, securityCode)
+	if err != nil {
+		return moex.SecurityParams{}, err
+	}
+	if len(info) == 0 {
+		return moex.SecurityParams{}, errors.New("security not found")
+	}
+	pricePrecision, err := AsInt(info["scale"])
+	if err != nil {
+		return moex.SecurityParams{}, err
+	}
+	priceStep, err := AsFloat64(info["min_price_step"])
+	if err != nil {
+		return moex.SecurityParams{}, err
+	}
+	lot, err := AsInt(info["lot_size"])
+	if err != nil {
+		return moex.SecurityParams{}, err
+	}
+	stepPriceParam, err := GetParamEx(c.quikService, classCode, securityCode, ParamNameSTEPPRICE)
+	if err != nil {
+		return moex.SecurityParams{}, err
+	}
+	priceStepCost, err := AsFloat64(stepPriceParam["param_value"])
+	if err != nil {
+		return moex.SecurityParams{}, err
+	}
+	return moex.SecurityParams{
+		PricePrecision: pricePrecision,
+		PriceStep:      priceStep,
+		PriceStepCost:  priceStepCost,
+		Lot:            lot,
+	}, nil
+}
+
 func (c *QuikConnector) HandleCallbacks(
 	ctx context.Context,
 	candles chan<- domain.Candle,
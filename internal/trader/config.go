@@ -1,7 +1,9 @@
 package trader
 
 import (
+	advisors "advisordev/internal/advisors_sample"
 	"encoding/xml"
+	"fmt"
 	"os"
 )
 
@@ -32,12 +34,43 @@ type StrategyConfig struct {
 }
 
 type SignalConfig struct {
-	Advisor       string  `xml:",attr"`
-	Security      string  `xml:",attr"`
-	Lever         float64 `xml:",attr"`
-	MaxLever      float64 `xml:",attr"`
-	StdVolatility float64 `xml:",attr"`
-	Weight        float64 `xml:",attr"`
+	// Имя, под которым публикуются сигналы (Strategy.Advisor). По умолчанию совпадает с Advisor.
+	// Нужно, если один советник работает с несколькими наборами параметров.
+	Name          string        `xml:",attr"`
+	Advisor       string        `xml:",attr"`
+	Security      string        `xml:",attr"`
+	Lever         float64       `xml:",attr"`
+	MaxLever      float64       `xml:",attr"`
+	StdVolatility float64       `xml:",attr"`
+	Weight        float64       `xml:",attr"`
+	Params        []ParamConfig `xml:"Param"`
+}
+
+type ParamConfig struct {
+	Name  string `xml:",attr"`
+	Value string `xml:",attr"`
+}
+
+func (config SignalConfig) SignalName() string {
+	if config.Name != "" {
+		return config.Name
+	}
+	return config.Advisor
+}
+
+func (config SignalConfig) AdvisorParams() (advisors.Params, error) {
+	var result = make(advisors.Params, len(config.Params))
+	for _, param := range config.Params {
+		if _, found := result[param.Name]; found {
+			return nil, fmt.Errorf("signal %v: duplicate param %v", config.SignalName(), param.Name)
+		}
+		value, err := advisors.ParseParamValue(param.Value)
+		if err != nil {
+			return nil, fmt.Errorf("signal %v: param %v: %w", config.SignalName(), param.Name, err)
+		}
+		result[param.Name] = value
+	}
+	return result, nil
 }
 
 func LoadConfig(filePath string) (TraderConfig, error) {
@@ -0,0 +1,333 @@
+package trader
+
+import (
+	"advisordev/internal/domain"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+// Советы и бары из JSON лога трейдера
+type TraderLog struct {
+	Advices []domain.Advice
+	Candles []domain.Candle
+}
+
+type logRecord struct {
+	Msg    string         `json:"msg"`
+	Advice *domain.Advice `json:"Advice"`
+	Candle *domain.Candle `json:"Candle"`
+}
+
+// Читает записи "Advice changed" и "New candle" из JSON логов трейдера
+func LoadTraderLog(filePaths []string) (TraderLog, error) {
+	var result TraderLog
+	for _, filePath := range filePaths {
+		var err = loadTraderLog(filePath, &result)
+		if err != nil {
+			return TraderLog{}, err
+		}
+	}
+	return result, nil
+}
+
+func loadTraderLog(filePath string, result *TraderLog) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	err = parseTraderLog(file, result)
+	if err != nil {
+		return fmt.Errorf("%v: %w", filePath, err)
+	}
+	return nil
+}
+
+func parseTraderLog(r io.Reader, result *TraderLog) error {
+	var scanner = bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	var line = 0
+	for scanner.Scan() {
+		line++
+		var text = scanner.Bytes()
+		if len(text) == 0 || text[0] != '{' {
+			continue
+		}
+		var record logRecord
+		var err = json.Unmarshal(text, &record)
+		if err != nil {
+			return fmt.Errorf("line %v: %w", line, err)
+		}
+		if record.Msg == "Advice changed" && record.Advice != nil {
+			result.Advices = append(result.Advices, *record.Advice)
+		} else if record.Msg == "New candle" && record.Candle != nil {
+			result.Candles = append(result.Candles, *record.Candle)
+		}
+	}
+	return scanner.Err()
+}
+
+// Расхождение бара в логе трейдера и в истории. Пустой бар, если его нет в логе или в истории.
+type CandleDiff struct {
+	SecurityCode string
+	DateTime     time.Time
+	Live         domain.Candle
+	History      domain.Candle
+}
+
+// Совет трейдера, позиция которого не совпала с позицией советника на истории
+type Drift struct {
+	Advisor         string
+	SecurityCode    string
+	DateTime        time.Time
+	LivePosition    float64
+	HistoryPosition float64
+	// На истории совета на этот бар нет
+	HistoryMissing bool
+	// Позиция на истории изменилась, а в логе совета на этот бар нет (например, трейдер пропустил бар).
+	// LivePosition - позиция последнего совета из лога.
+	LiveMissing bool
+	// Расхождения баров после прошлого расхождения позиций (не больше maxDriftCauses последних).
+	// Бар мог повлиять и на более поздние советы, поэтому совпавшие позиции между ними не учитываются.
+	Causes []CandleDiff
+}
+
+type DriftReport struct {
+	// Сколько советов из лога и изменений позиции на истории за период лога сравнили
+	Advices     int
+	Drifts      []Drift
+	CandleDiffs []CandleDiff
+}
+
+const maxDriftCauses = 10
+
+type candleKey struct {
+	securityCode string
+	dateTime     int64
+}
+
+type adviceKey struct {
+	advisor      string
+	securityCode string
+	dateTime     int64
+}
+
+// Прогоняет советники сигналов config по истории баров и сравнивает их советы с советами из лога трейдера
+func DetectDrift(
+	logger *slog.Logger,
+	candleStorage domain.ICandleStorage,
+	config TraderConfig,
+	securityInformator domain.ISecurityInformator,
+	traderLog TraderLog,
+) (DriftReport, error) {
+	var historyAdvices = make(map[adviceKey]domain.Advice)
+	var historyCandles = make(map[string][]domain.Candle)
+	for _, signalConfig := range config.Signals {
+		security, err := securityInformator.GetSecurityInfo(signalConfig.Security)
+		if err != nil {
+			return DriftReport{}, err
+		}
+		advisor, err := newSignalAdvisor(logger, signalConfig)
+		if err != nil {
+			return DriftReport{}, err
+		}
+		advisor = applySignalLever(advisor, signalConfig)
+		var _, loaded = historyCandles[security.Code]
+		var candles []domain.Candle
+		for candle, err := range candleStorage.Candles(security.Name) {
+			if err != nil {
+				return DriftReport{}, err
+			}
+			// как у брокера, бары приходят с кодом инструмента
+			candle.SecurityCode = security.Code
+			if !loaded {
+				candles = append(candles, candle)
+			}
+			var advice = advisor(candle)
+			if !advice.DateTime.IsZero() {
+				historyAdvices[adviceKey{advice.Advisor, advice.SecurityCode, advice.DateTime.Unix()}] = advice
+			}
+		}
+		if !loaded {
+			historyCandles[security.Code] = candles
+		}
+	}
+	var report = DriftReport{
+		CandleDiffs: compareCandles(traderLog.Candles, historyCandles),
+	}
+	var from, to = traderLog.span()
+	report.Advices, report.Drifts = compareAdvices(traderLog.Advices, historyAdvices, report.CandleDiffs, from, to)
+	return report, nil
+}
+
+// Бары из лога сравниваются с историей того же инструмента. Бары истории, которых нет в логе,
+// ищутся только внутри дней, за которые в логе есть бары инструмента.
+func compareCandles(liveCandles []domain.Candle, historyCandles map[string][]domain.Candle) []CandleDiff {
+	var live = make(map[candleKey]domain.Candle, len(liveCandles))
+	var liveDates = make(map[candleKey]bool)
+	for _, candle := range liveCandles {
+		live[candleKey{candle.SecurityCode, candle.DateTime.Unix()}] = candle
+		liveDates[candleKey{candle.SecurityCode, dateOf(candle.DateTime).Unix()}] = true
+	}
+	var history = make(map[candleKey]domain.Candle)
+	var result []CandleDiff
+	for securityCode, candles := range historyCandles {
+		for _, candle := range candles {
+			var key = candleKey{securityCode, candle.DateTime.Unix()}
+			history[key] = candle
+			if _, found := live[key]; !found && liveDates[candleKey{securityCode, dateOf(candle.DateTime).Unix()}] {
+				result = append(result, CandleDiff{SecurityCode: securityCode, DateTime: candle.DateTime, History: candle})
+			}
+		}
+	}
+	for key, liveCandle := range live {
+		if _, found := historyCandles[key.securityCode]; !found {
+			// инструмент не отслеживается сигналами конфига
+			continue
+		}
+		historyCandle, found := history[key]
+		if !found || !sameCandle(liveCandle, historyCandle) {
+			result = append(result, CandleDiff{
+				SecurityCode: key.securityCode,
+				DateTime:     liveCandle.DateTime,
+				Live:         liveCandle,
+				History:      historyCandle,
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].DateTime.Before(result[j].DateTime)
+	})
+	return result
+}
+
+func sameCandle(x, y domain.Candle) bool {
+	return x.OpenPrice == y.OpenPrice &&
+		x.HighPrice == y.HighPrice &&
+		x.LowPrice == y.LowPrice &&
+		x.ClosePrice == y.ClosePrice &&
+		x.Volume == y.Volume
+}
+
+// Советы из лога сравниваются с советами на истории на тот же бар. Изменения позиции на истории
+// за период лога [from, to], которых нет в логе, тоже считаются расхождениями.
+func compareAdvices(
+	liveAdvices []domain.Advice,
+	historyAdvices map[adviceKey]domain.Advice,
+	candleDiffs []CandleDiff,
+	from, to time.Time,
+) (int, []Drift) {
+	var advices = make([]domain.Advice, len(liveAdvices))
+	copy(advices, liveAdvices)
+	sort.SliceStable(advices, func(i, j int) bool {
+		return advices[i].DateTime.Before(advices[j].DateTime)
+	})
+	var live = make(map[adviceKey]domain.Advice, len(advices))
+	for _, advice := range advices {
+		live[adviceKey{advice.Advisor, advice.SecurityCode, advice.DateTime.Unix()}] = advice
+	}
+	var compared = 0
+	var result []Drift
+	for _, advice := range advices {
+		compared++
+		var key = adviceKey{advice.Advisor, advice.SecurityCode, advice.DateTime.Unix()}
+		historyAdvice, found := historyAdvices[key]
+		if found && math.Abs(historyAdvice.Position-advice.Position) < 1e-9 {
+			continue
+		}
+		result = append(result, Drift{
+			Advisor:         advice.Advisor,
+			SecurityCode:    advice.SecurityCode,
+			DateTime:        advice.DateTime,
+			LivePosition:    advice.Position,
+			HistoryPosition: historyAdvice.Position,
+			HistoryMissing:  !found,
+		})
+	}
+
+	// изменения позиции на истории, которых нет в логе
+	var history = make([]domain.Advice, 0, len(historyAdvices))
+	for _, advice := range historyAdvices {
+		history = append(history, advice)
+	}
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].DateTime.Before(history[j].DateTime)
+	})
+	var lastHistory = make(map[adviceKey]domain.Advice)
+	var lastLive = make(map[adviceKey]domain.Advice)
+	for _, advice := range history {
+		var key = adviceKey{advice.Advisor, advice.SecurityCode, advice.DateTime.Unix()}
+		var signalKey = adviceKey{advisor: advice.Advisor, securityCode: advice.SecurityCode}
+		prev, hasPrev := lastHistory[signalKey]
+		lastHistory[signalKey] = advice
+		liveAdvice, liveFound := live[key]
+		if liveFound {
+			lastLive[signalKey] = liveAdvice
+		}
+		if advice.DateTime.Before(from) || advice.DateTime.After(to) || liveFound {
+			continue
+		}
+		if hasPrev && math.Abs(prev.Position-advice.Position) < 1e-9 {
+			continue
+		}
+		compared++
+		result = append(result, Drift{
+			Advisor:         advice.Advisor,
+			SecurityCode:    advice.SecurityCode,
+			DateTime:        advice.DateTime,
+			LivePosition:    lastLive[signalKey].Position,
+			HistoryPosition: advice.Position,
+			LiveMissing:     true,
+		})
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].DateTime.Before(result[j].DateTime)
+	})
+	// время прошлого расхождения по советнику и инструменту
+	var lastDrift = make(map[adviceKey]time.Time)
+	for i := range result {
+		var drift = &result[i]
+		var signalKey = adviceKey{advisor: drift.Advisor, securityCode: drift.SecurityCode}
+		var from = lastDrift[signalKey]
+		lastDrift[signalKey] = drift.DateTime
+		for _, diff := range candleDiffs {
+			if diff.SecurityCode == drift.SecurityCode &&
+				diff.DateTime.After(from) &&
+				!diff.DateTime.After(drift.DateTime) {
+				drift.Causes = append(drift.Causes, diff)
+			}
+		}
+		if len(drift.Causes) > maxDriftCauses {
+			drift.Causes = drift.Causes[len(drift.Causes)-maxDriftCauses:]
+		}
+	}
+	return compared, result
+}
+
+// Период лога: от первого до последнего совета или бара
+func (l TraderLog) span() (time.Time, time.Time) {
+	var from, to time.Time
+	var add = func(t time.Time) {
+		if from.IsZero() || t.Before(from) {
+			from = t
+		}
+		if t.After(to) {
+			to = t
+		}
+	}
+	for _, advice := range l.Advices {
+		add(advice.DateTime)
+	}
+	for _, candle := range l.Candles {
+		add(candle.DateTime)
+	}
+	return from, to
+}
@@ -0,0 +1,73 @@
+package trader
+
+import (
+	"advisordev/internal/domain"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetectDriftFromLog(t *testing.T) {
+	const log = `{"time":"2025-04-01T12:00:01Z","level":"DEBUG","msg":"New candle","Candle":{"SecurityCode":"SiM5","DateTime":"2025-04-01T12:00:00+03:00","OpenPrice":100,"HighPrice":102,"LowPrice":99,"ClosePrice":105,"Volume":10}}
+{"time":"2025-04-01T12:00:01Z","level":"DEBUG","msg":"Advice changed","Advice":{"Advisor":"ma","SecurityCode":"SiM5","DateTime":"2025-04-01T12:00:00+03:00","Price":105,"Position":1}}
+{"time":"2025-04-01T12:00:02Z","level":"INFO","msg":"Register order","Price":105,"Volume":3}
+{"time":"2025-04-01T12:05:01Z","level":"DEBUG","msg":"Advice changed","Advice":{"Advisor":"ma","SecurityCode":"SiM5","DateTime":"2025-04-01T12:05:00+03:00","Price":101,"Position":0.5}}
+`
+	var traderLog TraderLog
+	var err = parseTraderLog(strings.NewReader(log), &traderLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(traderLog.Advices) != 2 || len(traderLog.Candles) != 1 {
+		t.Fatalf("parseTraderLog: advices %v candles %v", len(traderLog.Advices), len(traderLog.Candles))
+	}
+
+	var d1 = time.Date(2025, 4, 1, 12, 0, 0, 0, time.FixedZone("MSK", 3*60*60))
+	var d2 = d1.Add(5 * time.Minute)
+	var historyCandles = map[string][]domain.Candle{
+		"SiM5": {
+			{SecurityCode: "SiM5", DateTime: d1, OpenPrice: 100, HighPrice: 102, LowPrice: 99, ClosePrice: 101, Volume: 10},
+			{SecurityCode: "SiM5", DateTime: d2, OpenPrice: 101, HighPrice: 101, LowPrice: 101, ClosePrice: 101, Volume: 1},
+		},
+	}
+	var candleDiffs = compareCandles(traderLog.Candles, historyCandles)
+	if len(candleDiffs) != 2 {
+		t.Fatalf("compareCandles: %+v", candleDiffs)
+	}
+	if candleDiffs[0].Live.ClosePrice != 105 || candleDiffs[0].History.ClosePrice != 101 {
+		t.Errorf("changed candle: %+v", candleDiffs[0])
+	}
+	if !candleDiffs[1].Live.DateTime.IsZero() || !candleDiffs[1].History.DateTime.Equal(d2) {
+		t.Errorf("missing candle: %+v", candleDiffs[1])
+	}
+
+	var historyAdvices = map[adviceKey]domain.Advice{
+		{"ma", "SiM5", d1.Unix()}: {Advisor: "ma", SecurityCode: "SiM5", DateTime: d1, Position: 1},
+		{"ma", "SiM5", d2.Unix()}: {Advisor: "ma", SecurityCode: "SiM5", DateTime: d2, Position: 0.25},
+	}
+	var from, to = traderLog.span()
+	var compared, drifts = compareAdvices(traderLog.Advices, historyAdvices, candleDiffs, from, to)
+	if compared != 2 || len(drifts) != 1 {
+		t.Fatalf("compareAdvices: %v %+v", compared, drifts)
+	}
+	var drift = drifts[0]
+	if !drift.DateTime.Equal(d2) || drift.LivePosition != 0.5 || drift.HistoryPosition != 0.25 || len(drift.Causes) != 2 {
+		t.Errorf("drift: %+v", drift)
+	}
+
+	// на истории позиция изменилась на баре d3, а в логе совета нет
+	var d3 = d2.Add(5 * time.Minute)
+	historyAdvices[adviceKey{"ma", "SiM5", d3.Unix()}] = domain.Advice{Advisor: "ma", SecurityCode: "SiM5", DateTime: d3, Position: 0}
+	compared, drifts = compareAdvices(traderLog.Advices, historyAdvices, candleDiffs, from, d3)
+	if compared != 3 || len(drifts) != 2 {
+		t.Fatalf("compareAdvices: %v %+v", compared, drifts)
+	}
+	drift = drifts[1]
+	if !drift.LiveMissing || !drift.DateTime.Equal(d3) || drift.LivePosition != 0.5 || drift.HistoryPosition != 0 {
+		t.Errorf("live missing drift: %+v", drift)
+	}
+	// вне периода лога изменения на истории не проверяются
+	if _, drifts = compareAdvices(traderLog.Advices, historyAdvices, candleDiffs, from, to); len(drifts) != 1 {
+		t.Errorf("drifts outside log: %+v", drifts)
+	}
+}
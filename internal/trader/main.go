@@ -2,7 +2,6 @@ package trader
 
 import (
 	"advisordev/internal/domain"
-	"advisordev/internal/moex"
 	"advisordev/internal/quik"
 	"bufio"
 	"context"
@@ -34,12 +33,11 @@ func Run(
 	logger *slog.Logger,
 	candleStorage domain.ICandleStorage,
 	config TraderConfig,
+	securityInformator domain.ISecurityInformator,
 ) error {
 	var ctx, cancel = context.WithCancel(context.Background())
 	defer cancel()
 
-	var securityInformator = moex.NewFortsSecurityInformator()
-
 	var (
 		marketData        = make(chan domain.Candle)
 		signals           []ISignalService
@@ -79,7 +77,7 @@ func Run(
 			marketDataService = trader
 		}
 
-		var err = initStrategies(logger, trader, client.Portfolios, securityInformator, &strategies)
+		var err = initStrategies(logger, trader, client.Portfolios, securityInformator, time.Now, &strategies)
 		if err != nil {
 			return err
 		}
@@ -115,6 +113,7 @@ func initStrategies(
 	trader domain.ITrader,
 	portfolioConfigs []PortfolioConfig,
 	securityInformator domain.ISecurityInformator,
+	now func() time.Time,
 	strategies *[]IStrategyService,
 ) error {
 	connected, err := trader.IsConnected()
@@ -152,7 +151,7 @@ func initStrategies(
 			if err != nil {
 				return err
 			}
-			strategy, err := initStrategy(logger, strategyConfig, trader, portfolio, security, availableAmount)
+			strategy, err := initStrategy(logger, strategyConfig, trader, portfolio, security, availableAmount, now)
 			if err != nil {
 				return err
 			}
@@ -260,26 +259,44 @@ func mainCycle(
 				marketData = nil
 				continue
 			}
-			for _, signalService := range signals {
-				var advice = signalService.OnMarketData(candle)
-				if !advice.DateTime.IsZero() &&
-					advice.DateTime.After(start) {
+			var orderRegistered = onMarketData(logger, strategies, signals, candle, start)
+			if orderRegistered && checkPositionChan == nil {
+				checkPositionChan = time.After(30 * time.Second)
+			}
+		}
+	}
+}
 
-					// Может никто не подписан на сигнал, поэтому логируем
-					logger.Debug("Advice changed", "Advice", advice)
+// Передает бар сигналам, а их советы стратегиям. Общий для торговли и воспроизведения истории.
+func onMarketData(
+	logger *slog.Logger,
+	strategies []IStrategyService,
+	signals []ISignalService,
+	candle domain.Candle,
+	start time.Time,
+) bool {
+	// бары в логе нужны для поиска расхождений с историей (см. DetectDrift)
+	logger.Debug("New candle", "Candle", candle)
+	var result bool
+	for _, signalService := range signals {
+		var advice = signalService.OnMarketData(candle)
+		if !advice.DateTime.IsZero() &&
+			advice.DateTime.After(start) {
 
-					for _, strategy := range strategies {
-						var orderRegistered bool
-						var err = strategy.OnSignal(advice, &orderRegistered)
-						if err != nil {
-							logger.Error("OnSignal failed", "error", err)
-						}
-						if orderRegistered && checkPositionChan == nil {
-							checkPositionChan = time.After(30 * time.Second)
-						}
-					}
+			// Может никто не подписан на сигнал, поэтому логируем
+			logger.Debug("Advice changed", "Advice", advice)
+
+			for _, strategy := range strategies {
+				var orderRegistered bool
+				var err = strategy.OnSignal(advice, &orderRegistered)
+				if err != nil {
+					logger.Error("OnSignal failed", "error", err)
+				}
+				if orderRegistered {
+					result = true
 				}
 			}
 		}
 	}
+	return result
 }
@@ -0,0 +1,241 @@
+package trader
+
+import (
+	"advisordev/internal/domain"
+	"advisordev/internal/history"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// Заявка, которую трейдер отправил бы брокеру
+type ReplayOrder struct {
+	// Виртуальное время регистрации
+	Time      time.Time
+	Portfolio string
+	Security  string
+	Volume    int
+	Price     float64
+	Filled    bool
+	FillTime  time.Time
+	FillPrice float64
+	// Заявка снята в конце дня без исполнения
+	Cancelled bool
+}
+
+// Эквити всех портфелей на конец дня
+type ReplayDay struct {
+	Date   time.Time
+	Equity float64
+}
+
+type ReplayResult struct {
+	Orders []ReplayOrder
+	Days   []ReplayDay
+	// Начальный капитал всех портфелей
+	StartEquity float64
+}
+
+type replayPositionKey struct {
+	portfolio string
+	security  string
+}
+
+// Симуляция брокера для воспроизведения истории: виртуальное время по барам,
+// лимитные заявки исполняются, если следующие бары торговались по лимитной цене, и снимаются в конце дня.
+type ReplayTrader struct {
+	amount     float64
+	now        time.Time
+	cash       float64
+	positions  map[replayPositionKey]float64
+	levers     map[string]float64
+	lastPrices map[string]float64
+	orders     []ReplayOrder
+	pending    []int
+}
+
+func NewReplayTrader(amount float64) *ReplayTrader {
+	return &ReplayTrader{
+		amount:     amount,
+		positions:  make(map[replayPositionKey]float64),
+		levers:     make(map[string]float64),
+		lastPrices: make(map[string]float64),
+	}
+}
+
+// Виртуальное время
+func (t *ReplayTrader) Now() time.Time {
+	return t.now
+}
+
+func (t *ReplayTrader) IsConnected() (bool, error) {
+	return true, nil
+}
+
+func (t *ReplayTrader) IncomingAmount(portfolio domain.PortfolioInfo) (float64, error) {
+	return t.amount, nil
+}
+
+func (t *ReplayTrader) GetPosition(portfolio domain.PortfolioInfo, security domain.SecurityInfo) (float64, error) {
+	return t.positions[replayPositionKey{portfolio.Portfolio, security.Code}], nil
+}
+
+func (t *ReplayTrader) RegisterOrder(order domain.Order) error {
+	t.levers[order.Security.Code] = order.Security.Lever
+	t.pending = append(t.pending, len(t.orders))
+	t.orders = append(t.orders, ReplayOrder{
+		Time:      t.now,
+		Portfolio: order.Portfolio.Portfolio,
+		Security:  order.Security.Code,
+		Volume:    order.Volume,
+		Price:     order.Price,
+	})
+	return nil
+}
+
+func (t *ReplayTrader) GetLastCandles(security domain.SecurityInfo, timeframe string) ([]domain.Candle, error) {
+	return nil, nil
+}
+
+func (t *ReplayTrader) SubscribeCandles(security domain.SecurityInfo, timeframe string) error {
+	return nil
+}
+
+// Исполняет заявки на баре и сдвигает виртуальное время на конец бара
+func (t *ReplayTrader) onCandle(candle domain.Candle, candleDuration time.Duration) {
+	var pending = t.pending[:0]
+	for _, i := range t.pending {
+		var order = &t.orders[i]
+		if order.Security != candle.SecurityCode {
+			pending = append(pending, i)
+			continue
+		}
+		var fillPrice, filled = history.LimitFillPrice(order.Volume, order.Price, candle)
+		if !filled {
+			pending = append(pending, i)
+			continue
+		}
+		order.Filled = true
+		order.FillTime = candle.DateTime
+		order.FillPrice = fillPrice
+		t.positions[replayPositionKey{order.Portfolio, order.Security}] += float64(order.Volume)
+		t.cash -= float64(order.Volume) * fillPrice * t.levers[order.Security]
+	}
+	t.pending = pending
+	t.lastPrices[candle.SecurityCode] = candle.ClosePrice
+	t.now = candle.DateTime.Add(candleDuration)
+}
+
+func (t *ReplayTrader) cancelOrders() {
+	for _, i := range t.pending {
+		t.orders[i].Cancelled = true
+	}
+	t.pending = nil
+}
+
+// Эквити сверх начального капитала всех портфелей
+func (t *ReplayTrader) pnl() float64 {
+	var result = t.cash
+	for key, position := range t.positions {
+		result += position * t.lastPrices[key.security] * t.levers[key.security]
+	}
+	return result
+}
+
+// Бары сигналов всегда minutes5 (см. initSignal)
+const replayCandleDuration = 5 * time.Minute
+
+// Воспроизводит историю баров через SignalService и StrategyService, как при торговле.
+// Сигналы и стратегии берутся из config, брокер - ReplayTrader с капиталом amount на каждый портфель.
+// Каждый день стратегии создаются заново, как при ежедневном перезапуске трейдера:
+// позиция берется у брокера, базовая цена сбрасывается.
+func Replay(
+	logger *slog.Logger,
+	candleStorage domain.ICandleStorage,
+	config TraderConfig,
+	securityInformator domain.ISecurityInformator,
+	amount float64,
+) (ReplayResult, error) {
+	var trader = NewReplayTrader(amount)
+	var startEquity = 0.0
+	for _, client := range config.Clients {
+		for _, portfolio := range client.Portfolios {
+			if len(portfolio.Strategies) != 0 {
+				startEquity += amount
+			}
+		}
+	}
+	var initDayStrategies = func() ([]IStrategyService, error) {
+		var strategies []IStrategyService
+		for _, client := range config.Clients {
+			var err = initStrategies(logger, trader, client.Portfolios, securityInformator, trader.Now, &strategies)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return strategies, nil
+	}
+	strategies, err := initDayStrategies()
+	if err != nil {
+		return ReplayResult{}, err
+	}
+
+	var signals []ISignalService
+	// без хранилища баров: советники начинают с первого бара истории
+	err = initSignals(logger, config.Signals, securityInformator, nil, trader, &signals)
+	if err != nil {
+		return ReplayResult{}, err
+	}
+
+	var candles []domain.Candle
+	var seen = make(map[string]bool)
+	for _, signalConfig := range config.Signals {
+		security, err := securityInformator.GetSecurityInfo(signalConfig.Security)
+		if err != nil {
+			return ReplayResult{}, err
+		}
+		if seen[security.Code] {
+			continue
+		}
+		seen[security.Code] = true
+		for candle, err := range candleStorage.Candles(security.Name) {
+			if err != nil {
+				return ReplayResult{}, err
+			}
+			// как у брокера, бары приходят с кодом инструмента
+			candle.SecurityCode = security.Code
+			candles = append(candles, candle)
+		}
+	}
+	sort.SliceStable(candles, func(i, j int) bool {
+		return candles[i].DateTime.Before(candles[j].DateTime)
+	})
+
+	var result = ReplayResult{StartEquity: startEquity}
+	var lastDate time.Time
+	for _, candle := range candles {
+		var date = dateOf(candle.DateTime)
+		if !lastDate.IsZero() && date != lastDate {
+			trader.cancelOrders()
+			result.Days = append(result.Days, ReplayDay{Date: lastDate, Equity: startEquity + trader.pnl()})
+			strategies, err = initDayStrategies()
+			if err != nil {
+				return ReplayResult{}, err
+			}
+		}
+		lastDate = date
+		trader.onCandle(candle, replayCandleDuration)
+		onMarketData(logger, strategies, signals, candle, time.Time{})
+	}
+	if !lastDate.IsZero() {
+		trader.cancelOrders()
+		result.Days = append(result.Days, ReplayDay{Date: lastDate, Equity: startEquity + trader.pnl()})
+	}
+	result.Orders = trader.orders
+	return result, nil
+}
+
+func dateOf(t time.Time) time.Time {
+	var y, m, d = t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
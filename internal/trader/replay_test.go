@@ -0,0 +1,34 @@
+package trader
+
+import (
+	"advisordev/internal/domain"
+	"advisordev/internal/history"
+	"testing"
+)
+
+func TestReplayFillPrice(t *testing.T) {
+	var candle = domain.Candle{OpenPrice: 100, HighPrice: 105, LowPrice: 95, ClosePrice: 102}
+	var tests = []struct {
+		volume    int
+		price     float64
+		fillPrice float64
+		filled    bool
+	}{
+		{1, 101, 100, true},
+		{1, 97, 97, true},
+		{1, 94, 0, false},
+		// касание лимитной цены не исполняет заявку
+		{1, 95, 0, false},
+		{-1, 99, 100, true},
+		{-1, 104, 104, true},
+		{-1, 106, 0, false},
+		{-1, 105, 0, false},
+	}
+	for _, test := range tests {
+		var fillPrice, filled = history.LimitFillPrice(test.volume, test.price, candle)
+		if fillPrice != test.fillPrice || filled != test.filled {
+			t.Errorf("LimitFillPrice(%v, %v) = %v %v, want %v %v",
+				test.volume, test.price, fillPrice, filled, test.fillPrice, test.filled)
+		}
+	}
+}
@@ -24,7 +24,7 @@ func initSignal(
 	marketDataService IMarketDataService,
 ) (*SignalService, error) {
 	logger = logger.With(
-		"advisor", config.Advisor,
+		"advisor", config.SignalName(),
 		"security", config.Security)
 
 	security, err := securityInformator.GetSecurityInfo(config.Security)
@@ -34,7 +34,10 @@ func initSignal(
 
 	var candleInterval = domain.CandleIntervalMinutes5
 
-	var advisor = advisors.MainAdvisor(config.Advisor, config.StdVolatility, logger)
+	advisor, err := newSignalAdvisor(logger, config)
+	if err != nil {
+		return nil, err
+	}
 	var initAdvice domain.Advice
 
 	if candleStorage != nil {
@@ -75,9 +78,7 @@ func initSignal(
 	logger.Info("Init advice",
 		"advice", initAdvice)
 
-	advisor = applyStdDecorator(advisor, 0,
-		config.Lever*config.Weight,
-		config.MaxLever*config.Weight)
+	advisor = applySignalLever(advisor, config)
 
 	// initAdvice без stdDecorator поэтому не сохраняем его в lastAdvice, чтобы не путаться
 	return &SignalService{
@@ -89,6 +90,25 @@ func initSignal(
 	}, nil
 }
 
+// Советник сигнала без плеча
+func newSignalAdvisor(logger *slog.Logger, config SignalConfig) (domain.Advisor, error) {
+	params, err := config.AdvisorParams()
+	if err != nil {
+		return nil, err
+	}
+	advisor, err := advisors.MainAdvisor(config.Advisor, params, config.StdVolatility, logger)
+	if err != nil {
+		return nil, err
+	}
+	return advisors.WithName(advisor, config.SignalName()), nil
+}
+
+func applySignalLever(advisor domain.Advisor, config SignalConfig) domain.Advisor {
+	return applyStdDecorator(advisor, 0,
+		config.Lever*config.Weight,
+		config.MaxLever*config.Weight)
+}
+
 func (signal *SignalService) SubscribeMarketData() error {
 	return signal.marketDataService.SubscribeCandles(signal.security, signal.candleInterval)
 }
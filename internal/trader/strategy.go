@@ -16,6 +16,8 @@ type StrategyService struct {
 	amount    float64
 	position  int
 	basePrice float64
+	// Текущее время. При воспроизведении истории - виртуальное.
+	now func() time.Time
 }
 
 func initStrategy(
@@ -25,6 +27,7 @@ func initStrategy(
 	portfolio domain.PortfolioInfo,
 	security domain.SecurityInfo,
 	amount float64,
+	now func() time.Time,
 ) (*StrategyService, error) {
 	logger = logger.With(
 		//"portfolio", portfolio.Portfolio,
@@ -51,6 +54,7 @@ func initStrategy(
 		advisor:   config.Advisor,
 		amount:    amount,
 		position:  initPosition,
+		now:       now,
 	}, nil
 }
 
@@ -62,7 +66,7 @@ func (strategy *StrategyService) OnSignal(advice domain.Advice, outOrderRegister
 	}
 
 	// считаем, что сигнал слишком старый
-	if time.Since(advice.DateTime) >= 9*time.Minute {
+	if strategy.now().Sub(advice.DateTime) >= 9*time.Minute {
 		return nil
 	}
 